@@ -0,0 +1,106 @@
+package gearley
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// The benchmarks parse the classic stress cases of Earley parsers at several
+// input lengths, reporting the allocations the chart costs. Each input is
+// checked to parse, so that a broken parser does not pass for a fast one.
+
+func benchmarkParse(b *testing.B, g *Grammar, input func(n int) string, sizes ...int) {
+	for _, n := range sizes {
+		in := input(n)
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			if err := g.Parse(in); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				g.Parse(in)
+			}
+		})
+	}
+}
+
+func as(n int) string {
+	return strings.Repeat("a", n)
+}
+
+// BenchmarkParseAmbiguous parses S -> S S | 'a', which has a Catalan number
+// of trees and a cubic chart.
+func BenchmarkParseAmbiguous(b *testing.B) {
+	S := NewNonTerminal("S")
+	g := NewGrammar(
+		NewRule(S, S, S),             // S -> S S
+		NewRule(S, NewTerminal('a')), // S -> 'a'
+	)
+	benchmarkParse(b, g, as, 25, 50, 100)
+}
+
+// BenchmarkParseLeft parses S -> S 'a' | 'a', linear for Earley parsers.
+func BenchmarkParseLeft(b *testing.B) {
+	S := NewNonTerminal("S")
+	a := NewTerminal('a')
+	g := NewGrammar(
+		NewRule(S, S, a), // S -> S 'a'
+		NewRule(S, a),    // S -> 'a'
+	)
+	benchmarkParse(b, g, as, 1000, 10000, 100000)
+}
+
+// BenchmarkParseRight parses S -> 'a' S | 'a', quadratic without Leo's
+// optimization.
+func BenchmarkParseRight(b *testing.B) {
+	S := NewNonTerminal("S")
+	a := NewTerminal('a')
+	g := NewGrammar(
+		NewRule(S, a, S), // S -> 'a' S
+		NewRule(S, a),    // S -> 'a'
+	)
+	benchmarkParse(b, g, as, 100, 1000, 2000)
+}
+
+// BenchmarkParseNullable parses a grammar where most symbols may derive
+// the empty string.
+func BenchmarkParseNullable(b *testing.B) {
+	S := NewNonTerminal("S")
+	X := NewNonTerminal("X")
+	O := NewNonTerminal("O")
+	g := NewGrammar(
+		NewRule(S, S, X),                      // S -> S X
+		NewRule(S),                            // S ->
+		NewRule(X, O, O, O, NewTerminal('x')), // X -> O O O 'x'
+		NewRule(O, NewTerminal('o')),          // O -> 'o'
+		NewRule(O),                            // O ->
+	)
+	input := func(n int) string {
+		return strings.Repeat("ox", n/2)
+	}
+	benchmarkParse(b, g, input, 1000, 10000, 100000)
+}
+
+// BenchmarkParseArithmetic parses arithmetic expressions with the usual
+// precedence levels.
+func BenchmarkParseArithmetic(b *testing.B) {
+	E := NewNonTerminal("E")
+	T := NewNonTerminal("T")
+	F := NewNonTerminal("F")
+	digit := TerminalFunc("digit", unicode.IsDigit)
+	g := NewGrammar(
+		NewRule(E, E, NewTerminal('+'), T),                // E -> E '+' T
+		NewRule(E, T),                                     // E -> T
+		NewRule(T, T, NewTerminal('*'), F),                // T -> T '*' F
+		NewRule(T, F),                                     // T -> F
+		NewRule(F, NewTerminal('('), E, NewTerminal(')')), // F -> '(' E ')'
+		NewRule(F, digit),                                 // F -> [digit]
+	)
+	input := func(n int) string {
+		return "1" + strings.Repeat("+(2*3)", n/6)
+	}
+	benchmarkParse(b, g, input, 1000, 10000, 100000)
+}
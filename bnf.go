@@ -0,0 +1,123 @@
+package gearley
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/liuzl/gearley/internal/bnf"
+)
+
+// The BNF form of a grammar writes non terminals in angle brackets, rune
+// terminals as Go quoted strings, matchers in square brackets and the empty
+// alternative as "":
+//
+//	<T> ::= "a" "b"
+//	      | "a" <T> "b"
+//	<E> ::= ""
+//
+// A quoted string of several runes stands for one terminal per rune. A
+// backslash escapes a backslash or closing bracket in a name, and \n stands
+// for a newline. Comments run from # to the end of the line.
+
+// BNF returns the grammar in BNF, one group of alternatives per non terminal,
+// in order of first appearance. The first group is the start symbol's.
+func (g *Grammar) BNF() string {
+	order := []NonTerminal{}
+	groups := map[NonTerminal][]*Rule{}
+	for _, r := range g.rules {
+		if _, ok := groups[r.left]; !ok {
+			order = append(order, r.left)
+		}
+		groups[r.left] = append(groups[r.left], r)
+	}
+	var b strings.Builder
+	for _, n := range order {
+		head := bnf.Name('<', '>', n.name) + " ::= "
+		for i, r := range groups[n] {
+			if i == 0 {
+				b.WriteString(head)
+			} else {
+				b.WriteString(strings.Repeat(" ", len(head)-2) + "| ")
+			}
+			b.WriteString(bnfAlternative(r.right))
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func bnfAlternative(right []Symbol) string {
+	if len(right) == 0 {
+		return `""`
+	}
+	parts := make([]string, len(right))
+	for i, s := range right {
+		switch s := s.(type) {
+		case Terminal:
+			parts[i] = strconv.Quote(string(s.value))
+		case NonTerminal:
+			parts[i] = bnf.Name('<', '>', s.name)
+		case *Matcher:
+			parts[i] = bnf.Name('[', ']', s.name)
+		default:
+			// terminal kinds from other packages
+			parts[i] = s.String()
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseBNF reads a grammar written in BNF. The matchers it refers to are
+// looked up by name in matchers.
+func ParseBNF(text string, matchers ...*Matcher) (*Grammar, error) {
+	tokens, err := bnf.Lex(text)
+	if err != nil {
+		return nil, fmt.Errorf("gearley: bnf %w", err)
+	}
+	byName := bnf.ByName(matchers, (*Matcher).Name)
+	rules := []*Rule{}
+	i := 0
+	for i < len(tokens) {
+		if tokens[i].Kind != bnf.NonTerminal || i+1 == len(tokens) || tokens[i+1].Kind != bnf.Define {
+			return nil, fmt.Errorf("gearley: bnf line %d: expected <name> ::=", tokens[i].Line)
+		}
+		left := NewNonTerminal(tokens[i].Text)
+		i += 2
+		right := []Symbol{}
+		for {
+			if i == len(tokens) || tokens[i].Kind == bnf.Bar ||
+				(tokens[i].Kind == bnf.NonTerminal && i+1 < len(tokens) && tokens[i+1].Kind == bnf.Define) {
+				rules = append(rules, NewRule(left, right...))
+				right = []Symbol{}
+				if i < len(tokens) && tokens[i].Kind == bnf.Bar {
+					i++
+					continue
+				}
+				break
+			}
+			t := tokens[i]
+			switch t.Kind {
+			case bnf.NonTerminal:
+				right = append(right, NewNonTerminal(t.Text))
+			case bnf.String:
+				for _, r := range t.Text {
+					right = append(right, NewTerminal(r))
+				}
+			case bnf.Matcher:
+				m, ok := byName[t.Text]
+				if !ok {
+					return nil, fmt.Errorf("gearley: bnf line %d: unknown matcher %q", t.Line, t.Text)
+				}
+				right = append(right, m)
+			default:
+				return nil, fmt.Errorf("gearley: bnf line %d: unexpected ::=", t.Line)
+			}
+			i++
+		}
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("gearley: bnf: no rules")
+	}
+	return NewGrammar(rules...), nil
+}
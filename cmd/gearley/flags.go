@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// options are the flags and arguments of a command.
+type options struct {
+	runes       bool
+	format      string
+	maxTrees    int
+	trace       bool
+	grammarFile string
+	inputFile   string
+	input       string
+	stderr      io.Writer
+}
+
+var treeFormats = []string{"text", "sexpr", "json", "ptb", "dot", "forest"}
+
+func parseFlags(name string, args []string, stderr io.Writer) (*options, error) {
+	opts := &options{stderr: stderr}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&opts.runes, "runes", false, "the terminals are runes, not tokens")
+	fs.BoolVar(&opts.trace, "trace", false, "write every step of the parse to the standard error")
+	if name == "trees" {
+		fs.StringVar(&opts.format, "format", "text",
+			"the format of the trees: "+strings.Join(treeFormats, ", "))
+		fs.IntVar(&opts.maxTrees, "max-trees", 0, "print at most `n` trees, 0 for all")
+	}
+	fs.Usage = func() {
+		args := "GRAMMAR [INPUT]"
+		switch name {
+		case "check":
+			args = "GRAMMAR"
+		case "repl":
+			args = "[GRAMMAR]"
+		}
+		fmt.Fprintf(stderr, "usage: gearley %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	minArgs, maxArgs := 1, 2
+	switch name {
+	case "check":
+		maxArgs = 1
+	case "repl":
+		minArgs, maxArgs = 0, 1
+	}
+	if fs.NArg() < minArgs || fs.NArg() > maxArgs {
+		fs.Usage()
+		return nil, fmt.Errorf("bad arguments")
+	}
+	opts.grammarFile = fs.Arg(0)
+	opts.inputFile = fs.Arg(1)
+	if name == "trees" {
+		known := false
+		for _, f := range treeFormats {
+			known = known || f == opts.format
+		}
+		if !known {
+			fmt.Fprintf(stderr, "gearley: unknown format %q\n", opts.format)
+			return nil, fmt.Errorf("bad format")
+		}
+	}
+	return opts, nil
+}
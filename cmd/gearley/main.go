@@ -0,0 +1,141 @@
+// Command gearley parses input with a grammar written in BNF or JSON.
+//
+// Usage:
+//
+//	gearley check [flags] GRAMMAR
+//	gearley parse [flags] GRAMMAR [INPUT]
+//	gearley trees [flags] GRAMMAR [INPUT]
+//	gearley chart [flags] GRAMMAR [INPUT]
+//	gearley repl [flags] [GRAMMAR]
+//
+// check reports the problems of the grammar, parse whether the input is a
+// sentence of the grammar, trees its parse trees, and chart writes the parsing
+// chart as an HTML table. The input is read from INPUT, or from the standard
+// input. repl reads lines of input, and commands to edit the grammar and look
+// into the parses, from the standard input; :help lists the commands.
+//
+// By default the terminals of the grammar are tokens and the input is split on
+// white space, as in package earley3. With -runes the terminals are runes, as
+// in package gearley. A grammar file ending in .json is read as JSON, any
+// other as BNF. The grammars may use the matchers [number], [word] and [any]
+// on tokens, and [digit], [letter], [space] and [any] on runes.
+//
+// The exit status is 0 when the grammar is sound or the input accepted, 1 when
+// it is not, and 2 on errors.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, bufio.NewWriter(os.Stdout), os.Stderr))
+}
+
+const usage = `usage: gearley check|parse|trees|chart [flags] GRAMMAR [INPUT]
+       gearley repl [flags] [GRAMMAR]
+`
+
+const (
+	exitOK       = 0
+	exitRejected = 1
+	exitError    = 2
+)
+
+// run runs the command of args, and returns its exit status.
+func run(args []string, stdin io.Reader, stdout *bufio.Writer, stderr io.Writer) int {
+	defer stdout.Flush()
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+	cmd, ok := commands[args[0]]
+	if !ok && args[0] != "repl" {
+		fmt.Fprintf(stderr, "gearley: unknown command %q\n%s", args[0], usage)
+		return exitError
+	}
+	opts, err := parseFlags(args[0], args[1:], stderr)
+	if err != nil {
+		return exitError
+	}
+	if args[0] == "repl" {
+		return repl(opts, stdin, stdout)
+	}
+	tool, err := load(opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if args[0] != "check" {
+		if opts.input, err = readInput(opts.inputFile, stdin); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	return cmd(tool, opts, stdout, stderr)
+}
+
+var commands = map[string]func(tool, *options, io.Writer, io.Writer) int{
+	"check": check,
+	"parse": parse,
+	"trees": trees,
+	"chart": chart,
+}
+
+func readInput(file string, stdin io.Reader) (string, error) {
+	var data []byte
+	var err error
+	if file == "" || file == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return "", fmt.Errorf("gearley: %v", err)
+	}
+	return string(data), nil
+}
+
+func check(t tool, opts *options, stdout, stderr io.Writer) int {
+	problems := t.validate()
+	for _, err := range problems {
+		fmt.Fprintln(stdout, err)
+	}
+	if len(problems) > 0 {
+		return exitRejected
+	}
+	fmt.Fprintln(stdout, "ok")
+	return exitOK
+}
+
+func parse(t tool, opts *options, stdout, stderr io.Writer) int {
+	if err := t.parse(opts.input); err != nil {
+		fmt.Fprintln(stdout, err)
+		return exitRejected
+	}
+	fmt.Fprintln(stdout, "accepted")
+	return exitOK
+}
+
+func trees(t tool, opts *options, stdout, stderr io.Writer) int {
+	accepted, err := t.trees(opts.input, stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	if !accepted {
+		return exitRejected
+	}
+	return exitOK
+}
+
+func chart(t tool, opts *options, stdout, stderr io.Writer) int {
+	if err := t.chart(opts.input, stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
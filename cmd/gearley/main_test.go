@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runTool runs the tool on args and stdin, and returns its exit status and
+// outputs.
+func runTool(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr strings.Builder
+	w := bufio.NewWriter(&stdout)
+	status := run(args, strings.NewReader(stdin), w, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func Test_tokens(t *testing.T) {
+	grammar := writeFile(t, "sum.bnf", `
+<SUM> ::= <SUM> "+" <NUM> | <NUM>
+<NUM> ::= [number]
+`)
+	for _, c := range []struct {
+		args   []string
+		stdin  string
+		status int
+		out    string
+	}{
+		{[]string{"check", grammar}, "", exitOK, "ok\n"},
+		{[]string{"parse", grammar}, "1 + 2\n", exitOK, "accepted\n"},
+		{[]string{"parse", grammar}, "1 + +", exitRejected,
+			"unexpected \"+\" at 2, expected [number]\n"},
+		{[]string{"trees", "-format", "sexpr", grammar}, "1 + 2", exitOK,
+			"(ɣ (SUM (SUM (NUM 1)) + (NUM 2)))\n"},
+		{[]string{"trees", "-format", "ptb", grammar}, "1", exitOK,
+			"(ɣ\n  (SUM\n    (NUM 1)))\n"},
+		{[]string{"trees", "-format", "json", grammar}, "1", exitOK, `"token": "1"`},
+		{[]string{"trees", "-format", "dot", grammar}, "1", exitOK, "digraph tree {"},
+		{[]string{"trees", "-format", "forest", grammar}, "1", exitOK, "digraph forest {"},
+		{[]string{"trees", grammar}, "1", exitOK, "NUM -> [number] · [0-1]"},
+		{[]string{"trees", grammar}, "+", exitRejected, "unexpected \"+\" at 0"},
+		{[]string{"chart", grammar}, "1", exitOK, "<table class=\"earley-chart\">"},
+	} {
+		status, out, stderr := runTool(c.stdin, c.args...)
+		if status != c.status || !strings.Contains(out, c.out) {
+			t.Errorf("gearley %v < %q = %d\n%s%s, want %d\n%s", c.args, c.stdin,
+				status, out, stderr, c.status, c.out)
+		}
+	}
+
+	input := writeFile(t, "input.txt", "1 + 2 + 3")
+	if status, out, _ := runTool("", "parse", grammar, input); status != exitOK || out != "accepted\n" {
+		t.Errorf("parse from file = %d %q", status, out)
+	}
+	if status, _, stderr := runTool("1", "parse", "-trace", grammar); status != exitOK ||
+		!strings.Contains(stderr, "scan     S(1)") {
+		t.Errorf("trace = %d %q", status, stderr)
+	}
+}
+
+func Test_ambiguous(t *testing.T) {
+	grammar := writeFile(t, "s.bnf", `<S> ::= <S> <S> | "a"`)
+	status, out, _ := runTool("a a a a", "trees", "-format", "sexpr", "-max-trees", "3", grammar)
+	if status != exitOK || strings.Count(out, "\n") != 3 {
+		t.Errorf("trees -max-trees 3 = %d\n%s", status, out)
+	}
+}
+
+func Test_runes(t *testing.T) {
+	grammar := writeFile(t, "id.bnf", `
+<ID> ::= [letter] | <ID> [letter] | <ID> [digit]
+`)
+	for _, c := range []struct {
+		args   []string
+		stdin  string
+		status int
+		out    string
+	}{
+		{[]string{"check", "-runes", grammar}, "", exitOK, "ok\n"},
+		{[]string{"parse", "-runes", grammar}, "x25\n", exitOK, "accepted\n"},
+		{[]string{"parse", "-runes", grammar}, "2x", exitRejected,
+			"unexpected '2' at 0, expected [letter]\n"},
+		{[]string{"chart", "-runes", grammar}, "x", exitOK, "<th>S(0) &#39;x&#39;</th>"},
+		{[]string{"trees", "-runes", grammar}, "x1", exitOK,
+			"ID -> ID [digit] [0-2]\n  ID -> [letter] [0-1]\n    'x'\n  '1'\n"},
+		{[]string{"trees", "-runes", "-format", "sexpr", grammar}, "x1", exitOK,
+			"(ID (ID 'x') '1')\n"},
+		{[]string{"trees", "-runes", "-format", "ptb", grammar}, "x1", exitOK,
+			"(ID\n  (ID x)\n  1)\n"},
+		{[]string{"trees", "-runes", "-format", "json", grammar}, "x", exitOK, `"token": "x"`},
+		{[]string{"trees", "-runes", "-format", "dot", grammar}, "x", exitOK,
+			"n1 [shape=box, label=\"x\"];"},
+		{[]string{"trees", "-runes", "-format", "forest", grammar}, "x1", exitOK,
+			`"ID_0_2" -> t1;`},
+		{[]string{"trees", "-runes", grammar}, "1", exitRejected,
+			"unexpected '1' at 0, expected [letter]\n"},
+	} {
+		status, out, stderr := runTool(c.stdin, c.args...)
+		if status != c.status || !strings.Contains(out, c.out) {
+			t.Errorf("gearley %v < %q = %d\n%s%s, want %d\n%s", c.args, c.stdin,
+				status, out, stderr, c.status, c.out)
+		}
+	}
+}
+
+func Test_check(t *testing.T) {
+	grammar := writeFile(t, "bad.bnf", `
+<S> ::= <A> | <L>
+<A> ::= "a" | "a"
+<L> ::= <L> "l"
+`)
+	status, out, _ := runTool("", "check", grammar)
+	want := "earley3: rule L derives no sentence\nearley3: rule A has production \"a\" twice\n"
+	if status != exitRejected || out != want {
+		t.Errorf("check = %d\n%s, want\n%s", status, out, want)
+	}
+	if status, out, _ := runTool("", "check", "-runes", grammar); status != exitRejected ||
+		!strings.Contains(out, "gearley: L derives no string") {
+		t.Errorf("check -runes = %d\n%s", status, out)
+	}
+
+	json := writeFile(t, "g.json", `{"start": "S", "rules": [{"name": "S", "productions": [[{"kind": "token", "value": "s"}]]}]}`)
+	if status, out, _ := runTool("s", "parse", json); status != exitOK || out != "accepted\n" {
+		t.Errorf("parse with a JSON grammar = %d %q", status, out)
+	}
+}
+
+func Test_errors(t *testing.T) {
+	grammar := writeFile(t, "s.bnf", `<S> ::= "s"`)
+	for _, args := range [][]string{
+		{},
+		{"frobnicate", grammar},
+		{"parse"},
+		{"check", grammar, grammar},
+		{"trees", "-format", "xml", grammar},
+		{"parse", filepath.Join(t.TempDir(), "missing.bnf")},
+		{"parse", writeFile(t, "bad.bnf", `<S> ::= <T>`)},
+	} {
+		if status, _, stderr := runTool("s", args...); status != exitError || stderr == "" {
+			t.Errorf("gearley %v = %d, %q", args, status, stderr)
+		}
+	}
+}
+
+func Test_repl(t *testing.T) {
+	grammar := writeFile(t, "sum.bnf", `
+<SUM> ::= <SUM> "+" <NUM> | <NUM>
+<NUM> ::= [number]
+`)
+	stdin := strings.Join([]string{
+		"1 +",
+		"1 + 2",
+		":ambig",
+		`:rule <SUM> ::= <SUM> "+" <SUM>`,
+		"1 + 2 + 3",
+		":ambig",
+		":rule <SUM> ::= <UNDEFINED>",
+		":drop NUM",
+		":drop SUM",
+		":grammar",
+		"1",
+		":bogus",
+		":quit",
+		"never read",
+	}, "\n")
+	status, out, _ := runTool(stdin, "repl", grammar)
+	if status != exitOK {
+		t.Errorf("repl = %d", status)
+	}
+	for _, want := range []string{
+		"> unexpected end of input at 2, expected [number]\n",
+		"> accepted, 1 tree\nexpected next: +\nɣ -> SUM · [0-3]\n",
+		"> no ambiguity\n",
+		"> accepted, 6 trees\n",
+		"SUM [0-5]: 3 derivations\n  SUM [0-3] + NUM [4-5]\n",
+		"> earley3: bnf: undefined rule <UNDEFINED>\n",
+		"> earley3: bnf: undefined rule <NUM>\n",
+		"> > <NUM> ::= [number]\n> accepted, 1 tree\nɣ -> NUM",
+		"> unknown command :bogus",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("repl: no %q in\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "never read") {
+		t.Errorf("repl went on after :quit")
+	}
+
+	status, out, _ = runTool(":trace\n:rule <S> ::= \"a\" | \"a\"\na\n:check\n:chart", "repl", "-runes")
+	for _, want := range []string{
+		"> tracing on\n",
+		"scan     S(1) S -> 'a'● (0)",
+		"accepted, 2 trees\nS -> 'a' [0-1]\n  'a'\n",
+		"gearley: rule S -> 'a' is given twice\n",
+		"S(0) 'a'\n====",
+	} {
+		if status != exitOK || !strings.Contains(out, want) {
+			t.Errorf("repl -runes: no %q in\n%s", want, out)
+		}
+	}
+	// a trace for a and one for :chart, with a scan per rule each
+	if n := strings.Count(out, "scan     S(1) S -> 'a'● (0)"); n != 4 {
+		t.Errorf("repl -runes: %d scans traced, want 4 in\n%s", n, out)
+	}
+
+	status, out, _ = runTool(":rule <E> ::= <E> \"+\" <E> | \"1\"\n1+1\n:ambig\n1+1+1\n:ambig", "repl", "-runes")
+	for _, want := range []string{
+		"> no ambiguity\n",
+		"> E [0-5]: 2 derivations\n  E [0-3] '+' E [4-5]\n  E [0-1] '+' E [2-5]\n",
+	} {
+		if status != exitOK || !strings.Contains(out, want) {
+			t.Errorf("repl -runes :ambig: no %q in\n%s", want, out)
+		}
+	}
+}
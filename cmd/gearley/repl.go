@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const replHelp = `Type a line of input to parse it, or a command:
+  :load FILE    load the grammar in FILE
+  :rule BNF     add the rules in BNF to the grammar
+  :drop NAME    remove the rules of NAME from the grammar
+  :grammar      print the grammar
+  :check        print the problems of the grammar
+  :chart        print the chart of the last input
+  :trace        turn the tracing of parses on or off
+  :ambig        print the ambiguities of the last input
+  :help         print this help
+  :quit         leave
+`
+
+// session is the state of a REPL.
+type session struct {
+	opts *options
+	out  *bufio.Writer
+	// the grammar in BNF, and its tool; nil before any rule
+	text string
+	tool tool
+	last string
+}
+
+// repl reads commands and input lines from stdin until its end or :quit.
+func repl(opts *options, stdin io.Reader, stdout *bufio.Writer) int {
+	s := &session{opts: opts, out: stdout}
+	// traces go with the rest of the output
+	opts.stderr = stdout
+	if opts.grammarFile != "" {
+		s.load(opts.grammarFile)
+	}
+	fmt.Fprintln(stdout, "gearley: type :help for the commands")
+	scanner := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "> ")
+		stdout.Flush()
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			return exitOK
+		}
+		if !s.do(scanner.Text()) {
+			return exitOK
+		}
+	}
+}
+
+// do runs a line, and tells whether to go on.
+func (s *session) do(line string) bool {
+	if !strings.HasPrefix(line, ":") {
+		s.last = line
+		if s.needTool() {
+			s.tool.explore(line, s.out)
+		}
+		return true
+	}
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case ":quit", ":q":
+		return false
+	case ":help":
+		fmt.Fprint(s.out, replHelp)
+	case ":load":
+		s.load(arg)
+	case ":rule":
+		s.setGrammar(s.text + "\n" + arg)
+	case ":drop":
+		s.drop(arg)
+	case ":grammar":
+		fmt.Fprint(s.out, s.text)
+	case ":check":
+		if s.needTool() {
+			check(s.tool, s.opts, s.out, s.out)
+		}
+	case ":chart":
+		if s.needTool() {
+			s.tool.dump(s.last, s.out)
+		}
+	case ":trace":
+		s.opts.trace = !s.opts.trace
+		fmt.Fprintf(s.out, "tracing %s\n", map[bool]string{true: "on", false: "off"}[s.opts.trace])
+	case ":ambig":
+		if s.needTool() {
+			s.tool.ambiguities(s.last, s.out)
+		}
+	default:
+		fmt.Fprintf(s.out, "unknown command %s, type :help for the commands\n", cmd)
+	}
+	return true
+}
+
+func (s *session) needTool() bool {
+	if s.tool == nil {
+		fmt.Fprintln(s.out, "no grammar yet: :load a file or add a :rule")
+	}
+	return s.tool != nil
+}
+
+func (s *session) load(file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(s.out, "gearley: %v\n", err)
+		return
+	}
+	t, err := newTool(data, strings.HasSuffix(file, ".json"), s.opts)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	s.tool, s.text = t, t.bnf()
+}
+
+// setGrammar replaces the grammar by the one of text, unless it is wrong.
+func (s *session) setGrammar(text string) {
+	if strings.TrimSpace(text) == "" {
+		s.tool, s.text = nil, ""
+		return
+	}
+	t, err := newTool([]byte(text), false, s.opts)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	s.tool, s.text = t, t.bnf()
+}
+
+// drop removes the group of alternatives of name from the grammar: its first
+// line starts with <name> ::=, the others with |.
+func (s *session) drop(name string) {
+	name = strings.Trim(name, "<>")
+	kept := []string{}
+	dropping, found := false, false
+	for _, line := range strings.SplitAfter(s.text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(line, "<") {
+			dropping = strings.HasPrefix(line, "<"+name+"> ::=")
+			found = found || dropping
+		}
+		if !dropping && trimmed != "" {
+			kept = append(kept, line)
+		}
+	}
+	if !found {
+		fmt.Fprintf(s.out, "no rule %s\n", name)
+		return
+	}
+	s.setGrammar(strings.Join(kept, ""))
+}
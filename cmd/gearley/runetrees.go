@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/liuzl/gearley"
+)
+
+// writeTrees writes the trees of package gearley in the format of the trees
+// command, as close as runes allow to the ones package earley3 writes for
+// tokens.
+func writeTrees(w io.Writer, trees []*gearley.Tree, format string) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(trees, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case "dot":
+		for _, t := range trees {
+			if err := t.WriteDOT(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i, t := range trees {
+		switch format {
+		case "sexpr":
+			fmt.Fprintln(w, t)
+		case "ptb":
+			fmt.Fprint(w, t.PTB())
+		default:
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			t.Print(w)
+		}
+	}
+	return nil
+}
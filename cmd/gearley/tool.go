@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/liuzl/gearley"
+	"github.com/liuzl/gearley/earley3"
+	"github.com/liuzl/gearley/semiring"
+	"github.com/liuzl/gearley/trace"
+)
+
+// tool runs the commands with one of the two parsers.
+type tool interface {
+	validate() []error
+	parse(input string) error
+	// trees writes the trees of input to w, and tells whether there are any
+	trees(input string, w io.Writer) (bool, error)
+	chart(input string, w io.Writer) error
+
+	// the grammar in BNF
+	bnf() string
+	// explore writes what the REPL tells about input
+	explore(input string, w io.Writer)
+	ambiguities(input string, w io.Writer)
+	// dump writes the chart of input for reading in a terminal
+	dump(input string, w io.Writer)
+}
+
+// load reads the grammar file of opts for the parser it asks for.
+func load(opts *options) (tool, error) {
+	data, err := os.ReadFile(opts.grammarFile)
+	if err != nil {
+		return nil, fmt.Errorf("gearley: %v", err)
+	}
+	return newTool(data, strings.HasSuffix(opts.grammarFile, ".json"), opts)
+}
+
+// newTool reads the grammar in data, in JSON or BNF.
+func newTool(data []byte, isJSON bool, opts *options) (tool, error) {
+	var err error
+	if opts.runes {
+		var g *gearley.Grammar
+		if isJSON {
+			g, err = gearley.UnmarshalGrammar(data, runeMatchers...)
+		} else {
+			g, err = gearley.ParseBNF(string(data), runeMatchers...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &runeTool{g: g, opts: opts}, nil
+	}
+	var start *earley3.Rule
+	if isJSON {
+		start, err = earley3.UnmarshalGrammar(data, tokenMatchers...)
+	} else {
+		start, err = earley3.ParseBNF(string(data), tokenMatchers...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g, err := earley3.Compile(start)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenTool{g: g, opts: opts}, nil
+}
+
+var runeMatchers = []*gearley.Matcher{
+	gearley.TerminalFunc("digit", unicode.IsDigit),
+	gearley.TerminalFunc("letter", unicode.IsLetter),
+	gearley.TerminalFunc("space", unicode.IsSpace),
+	gearley.TerminalFunc("any", func(rune) bool { return true }),
+}
+
+var tokenMatchers = []*earley3.Matcher{
+	earley3.TerminalFunc("number", func(token string) bool {
+		_, err := strconv.ParseFloat(token, 64)
+		return err == nil
+	}),
+	earley3.TerminalFunc("word", func(token string) bool {
+		return strings.IndexFunc(token, func(r rune) bool { return !unicode.IsLetter(r) }) < 0
+	}),
+	earley3.TerminalFunc("any", func(string) bool { return true }),
+}
+
+// tokenTool parses tokens with package earley3.
+type tokenTool struct {
+	g    *earley3.Grammar
+	opts *options
+}
+
+func (t *tokenTool) parser(input string) *earley3.Parser {
+	opts := []earley3.Option{earley3.WithMaxTrees(t.opts.maxTrees)}
+	if t.opts.trace {
+		opts = append(opts, earley3.WithTracer(trace.NewText(t.opts.stderr)))
+	}
+	return t.g.Parse(input, opts...)
+}
+
+func (t *tokenTool) validate() []error {
+	return earley3.Validate(t.g.Start())
+}
+
+func (t *tokenTool) parse(input string) error {
+	return t.parser(input).Err()
+}
+
+func (t *tokenTool) trees(input string, w io.Writer) (bool, error) {
+	p := t.parser(input)
+	if err := p.Err(); err != nil {
+		fmt.Fprintln(w, err)
+		return false, nil
+	}
+	if t.opts.format == "forest" {
+		return true, p.WriteForestDOT(w)
+	}
+	trees, err := p.TreesContext(context.Background())
+	var limit *earley3.LimitError
+	if errors.As(err, &limit) && limit.Limit == earley3.LimitTrees {
+		// the first trees are what was asked for
+		err = nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch t.opts.format {
+	case "json":
+		data, err := json.MarshalIndent(trees, "", "  ")
+		if err != nil {
+			return false, err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return true, err
+	case "dot":
+		for _, tree := range trees {
+			if err := tree.WriteDOT(w); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	for i, tree := range trees {
+		switch t.opts.format {
+		case "sexpr":
+			fmt.Fprintln(w, tree.SExpr())
+		case "ptb":
+			fmt.Fprint(w, tree.PTB())
+		default:
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			tree.Print(w)
+		}
+	}
+	return true, nil
+}
+
+func (t *tokenTool) chart(input string, w io.Writer) error {
+	return t.parser(input).WriteChartHTML(w)
+}
+
+func (t *tokenTool) bnf() string {
+	text, _ := earley3.FormatBNF(t.g.Start())
+	return text
+}
+
+func (t *tokenTool) explore(input string, w io.Writer) {
+	p := t.parser(input)
+	if err := p.Err(); err != nil {
+		fmt.Fprintln(w, err)
+		return
+	}
+	n := earley3.Score[uint64](p, semiring.Counting{}, func(*earley3.Production) uint64 { return 1 })
+	fmt.Fprintf(w, "accepted, %s\n", plural(n, "tree"))
+	if expected := p.Expected(); len(expected) > 0 {
+		fmt.Fprintf(w, "expected next: %s\n", strings.Join(expected, " or "))
+	}
+	trees, _ := t.g.Parse(input, earley3.WithMaxTrees(1)).TreesContext(context.Background())
+	if len(trees) > 0 {
+		trees[0].Print(w)
+	}
+}
+
+func (t *tokenTool) ambiguities(input string, w io.Writer) {
+	p := t.parser(input)
+	if err := p.Err(); err != nil {
+		fmt.Fprintln(w, err)
+		return
+	}
+	ambiguities := p.Ambiguities()
+	if len(ambiguities) == 0 {
+		fmt.Fprintln(w, "no ambiguity")
+	}
+	for _, a := range ambiguities {
+		fmt.Fprintf(w, "%s [%d-%d]: %s\n", a.Symbol, a.Start, a.End,
+			plural(uint64(len(a.Alternatives)), "derivation"))
+		for _, alternative := range a.Alternatives {
+			fmt.Fprintf(w, "  %s\n", strings.Join(alternative, " "))
+		}
+	}
+}
+
+func (t *tokenTool) dump(input string, w io.Writer) {
+	fmt.Fprint(w, t.parser(input))
+}
+
+func plural(n uint64, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// runeTool parses runes with package gearley.
+type runeTool struct {
+	g    *gearley.Grammar
+	opts *options
+}
+
+func (t *runeTool) options() []gearley.Option {
+	if t.opts.trace {
+		return []gearley.Option{gearley.WithTracer(trace.NewText(t.opts.stderr))}
+	}
+	return nil
+}
+
+// runes drops the line break ending input, which is not part of it for
+// most files and for sure when typed.
+func runes(input string) string {
+	input = strings.TrimSuffix(input, "\n")
+	return strings.TrimSuffix(input, "\r")
+}
+
+func (t *runeTool) validate() []error {
+	return t.g.Validate()
+}
+
+func (t *runeTool) parse(input string) error {
+	return t.g.Parse(runes(input), t.options()...)
+}
+
+func (t *runeTool) trees(input string, w io.Writer) (bool, error) {
+	input = runes(input)
+	opts := t.options()
+	if t.opts.format == "forest" {
+		f, err := gearley.NewEarleyParser(t.g, opts...).Parse(input)
+		if rejected(w, err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, f.WriteDOT(w)
+	}
+	if t.opts.maxTrees > 0 {
+		opts = append(opts, gearley.WithMaxTrees(t.opts.maxTrees))
+	}
+	trees, err := t.g.Trees(input, opts...)
+	if rejected(w, err) {
+		return false, nil
+	}
+	var limit *gearley.LimitError
+	if errors.As(err, &limit) && limit.Limit == gearley.LimitTrees {
+		// the first trees are what was asked for
+		err = nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, writeTrees(w, trees, t.opts.format)
+}
+
+// rejected writes err to w if it is a *gearley.ParseError, and tells whether
+// it was.
+func rejected(w io.Writer, err error) bool {
+	var pe *gearley.ParseError
+	if errors.As(err, &pe) {
+		fmt.Fprintln(w, err)
+		return true
+	}
+	return false
+}
+
+func (t *runeTool) chart(input string, w io.Writer) error {
+	return t.g.WriteChartHTML(w, runes(input), t.options()...)
+}
+
+func (t *runeTool) bnf() string {
+	return t.g.BNF()
+}
+
+func (t *runeTool) explore(input string, w io.Writer) {
+	input = runes(input)
+	// the count is the parse traced: the other ones would trace it again
+	n, err := gearley.Score[uint64](t.g, input, semiring.Counting{}, func(*gearley.Rule) uint64 { return 1 }, t.options()...)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return
+	}
+	if n == 0 {
+		fmt.Fprintln(w, t.g.Parse(input))
+		return
+	}
+	fmt.Fprintf(w, "accepted, %s\n", plural(n, "tree"))
+	expected, err := t.g.Expected(input)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return
+	}
+	if len(expected) > 0 {
+		names := make([]string, len(expected))
+		for i, s := range expected {
+			names[i] = s.String()
+		}
+		fmt.Fprintf(w, "expected next: %s\n", strings.Join(names, " or "))
+	}
+	trees, _ := t.g.Trees(input, gearley.WithMaxTrees(1))
+	if len(trees) > 0 {
+		trees[0].Print(w)
+	}
+}
+
+func (t *runeTool) ambiguities(input string, w io.Writer) {
+	f, err := gearley.NewEarleyParser(t.g, t.options()...).Parse(runes(input))
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return
+	}
+	ambiguities := f.Ambiguities()
+	if len(ambiguities) == 0 {
+		fmt.Fprintln(w, "no ambiguity")
+		return
+	}
+	for _, n := range ambiguities {
+		fmt.Fprintf(w, "%s: %s\n", n, plural(uint64(len(n.Families)), "derivation"))
+		for _, family := range n.Families {
+			alternative := make([]string, len(family.Children))
+			for i, child := range family.Children {
+				alternative[i] = child.String()
+			}
+			fmt.Fprintf(w, "  %s\n", strings.Join(alternative, " "))
+		}
+	}
+}
+
+func (t *runeTool) dump(input string, w io.Writer) {
+	if err := t.g.WriteChart(w, runes(input), t.options()...); err != nil {
+		fmt.Fprintln(w, err)
+	}
+}
@@ -0,0 +1,53 @@
+package cyk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/liuzl/gearley"
+	"github.com/liuzl/gearley/internal/differential"
+)
+
+// The benchmarks parse the same inputs with the CYK parser and the Earley
+// parser of package gearley.
+
+func benchmarkParsers(b *testing.B, bnf string, input func(n int) string, sizes ...int) {
+	g, err := gearley.ParseBNF(bnf)
+	if err != nil {
+		b.Fatal(err)
+	}
+	p, err := New(g)
+	if err != nil {
+		b.Fatal(err)
+	}
+	parsers := []struct {
+		name   string
+		parser gearley.Parser
+	}{{"cyk", p}, {"earley", gearley.NewEarleyParser(g)}}
+	for _, n := range sizes {
+		in := input(n)
+		for _, parser := range parsers {
+			b.Run(fmt.Sprintf("%s/n=%d", parser.name, n), func(b *testing.B) {
+				if err := parser.parser.Recognize(in); err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					parser.parser.Recognize(in)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRecognizeAmbiguous(b *testing.B) {
+	benchmarkParsers(b, differential.Grammars()["ambiguous"], func(n int) string { return strings.Repeat("a", n) }, 10, 50, 100)
+}
+
+func BenchmarkRecognizeArithmetic(b *testing.B) {
+	benchmarkParsers(b, differential.Grammars()["arithmetic"], func(n int) string {
+		return "1" + strings.Repeat("+(2*1)", n/6)
+	}, 10, 50, 100)
+}
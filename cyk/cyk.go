@@ -0,0 +1,208 @@
+// Package cyk parses with the Cocke-Younger-Kasami algorithm: it fills in a
+// table of the non terminals deriving each span of the input, bottom-up,
+// from the Chomsky Normal Form of a grammar.
+//
+// Its parses take a time cubic in the length of the input, whatever the
+// grammar, where the Earley parser of package gearley is often linear. It
+// is there to compare against.
+package cyk
+
+import (
+	"fmt"
+
+	"github.com/liuzl/gearley"
+	"github.com/liuzl/gearley/transform"
+)
+
+// Parser is the gearley.Parser of the CYK algorithm.
+//
+// It parses with the Chomsky Normal Form of its grammar: the forests of
+// Parse are forests of that grammar, and Restore turns their trees into
+// trees of the grammar the parser was made for.
+type Parser struct {
+	cnf *transform.Result
+	// ids numbers the non terminals of the CNF grammar
+	ids map[gearley.NonTerminal]int
+	// the rules of the CNF grammar, by kind
+	terminals []terminalRule
+	binaries  []binaryRule
+	start     int
+	// empty is the rule rewriting the start symbol to the empty string,
+	// if there is one
+	empty *gearley.Rule
+}
+
+// terminalRule is a rule A -> t.
+type terminalRule struct {
+	rule     *gearley.Rule
+	left     int
+	terminal gearley.Symbol
+}
+
+// binaryRule is a rule A -> B C.
+type binaryRule struct {
+	rule           *gearley.Rule
+	left, one, two int
+}
+
+var _ gearley.Parser = (*Parser)(nil)
+
+// New returns the CYK parser of g. It fails when g has no Chomsky Normal
+// Form: when its start symbol derives no string.
+func New(g *gearley.Grammar) (*Parser, error) {
+	cnf, err := transform.CNF(g)
+	if err != nil {
+		return nil, err
+	}
+	p := &Parser{cnf: cnf, ids: map[gearley.NonTerminal]int{}}
+	id := func(n gearley.NonTerminal) int {
+		if _, ok := p.ids[n]; !ok {
+			p.ids[n] = len(p.ids)
+		}
+		return p.ids[n]
+	}
+	p.start = id(cnf.Grammar().Start())
+	for _, r := range cnf.Grammar().Rules() {
+		right := r.Right()
+		switch len(right) {
+		case 0:
+			p.empty = r
+		case 1:
+			p.terminals = append(p.terminals, terminalRule{rule: r, left: id(r.Left()), terminal: right[0]})
+		case 2:
+			p.binaries = append(p.binaries, binaryRule{
+				rule: r,
+				left: id(r.Left()),
+				one:  id(right[0].(gearley.NonTerminal)),
+				two:  id(right[1].(gearley.NonTerminal)),
+			})
+		}
+	}
+	return p, nil
+}
+
+// Grammar returns the Chomsky Normal Form the parser parses with.
+func (p *Parser) Grammar() *gearley.Grammar {
+	return p.cnf.Grammar()
+}
+
+// Restore returns the tree of the grammar of the parser that t, a tree of a
+// forest of Parse, stands for.
+func (p *Parser) Restore(t *gearley.Tree) (*gearley.Tree, error) {
+	return p.cnf.Restore(t)
+}
+
+// table holds the non terminals deriving each span of the input.
+type table struct {
+	n       int
+	symbols int
+	cells   []bool
+}
+
+func (t *table) has(start, end, symbol int) bool {
+	return t.cells[(start*(t.n+1)+end)*t.symbols+symbol]
+}
+
+func (t *table) set(start, end, symbol int) {
+	t.cells[(start*(t.n+1)+end)*t.symbols+symbol] = true
+}
+
+// fill returns the table of runes.
+func (p *Parser) fill(runes []rune) *table {
+	n := len(runes)
+	t := &table{n: n, symbols: len(p.ids), cells: make([]bool, (n+1)*(n+1)*len(p.ids))}
+	for i, r := range runes {
+		for _, tr := range p.terminals {
+			if tr.terminal.Match(r) {
+				t.set(i, i+1, tr.left)
+			}
+		}
+	}
+	for length := 2; length <= n; length++ {
+		for start := 0; start+length <= n; start++ {
+			end := start + length
+			for mid := start + 1; mid < end; mid++ {
+				for _, br := range p.binaries {
+					if t.has(start, mid, br.one) && t.has(mid, end, br.two) {
+						t.set(start, end, br.left)
+					}
+				}
+			}
+		}
+	}
+	return t
+}
+
+// accepts reports whether the table of runes derives the start symbol.
+func (p *Parser) accepts(t *table) bool {
+	if t.n == 0 {
+		return p.empty != nil
+	}
+	return t.has(0, t.n, p.start)
+}
+
+// Recognize returns nil if input is a sentence of the grammar.
+func (p *Parser) Recognize(input string) error {
+	runes := []rune(input)
+	if !p.accepts(p.fill(runes)) {
+		return fmt.Errorf("cyk: %q is not a sentence", input)
+	}
+	return nil
+}
+
+// Parse returns the forest of the trees of input in the Chomsky Normal Form
+// of the grammar.
+func (p *Parser) Parse(input string) (*gearley.Forest, error) {
+	runes := []rune(input)
+	t := p.fill(runes)
+	if !p.accepts(t) {
+		return nil, fmt.Errorf("cyk: %q is not a sentence", input)
+	}
+	start := p.cnf.Grammar().Start()
+	if len(runes) == 0 {
+		root := &gearley.ForestNode{Symbol: start}
+		root.Families = []*gearley.Family{{Rule: p.empty}}
+		return &gearley.Forest{Root: root}, nil
+	}
+
+	symbols := make([]gearley.NonTerminal, len(p.ids))
+	for n, id := range p.ids {
+		symbols[id] = n
+	}
+	leaves := make([]*gearley.ForestNode, len(runes))
+	for i, r := range runes {
+		leaves[i] = &gearley.ForestNode{Rune: r, Start: i, End: i + 1}
+	}
+	nodes := map[[3]int]*gearley.ForestNode{}
+	var node func(symbol, start, end int) *gearley.ForestNode
+	node = func(symbol, start, end int) *gearley.ForestNode {
+		key := [3]int{symbol, start, end}
+		if n, ok := nodes[key]; ok {
+			return n
+		}
+		n := &gearley.ForestNode{Symbol: symbols[symbol], Start: start, End: end}
+		nodes[key] = n
+		if end == start+1 {
+			for _, tr := range p.terminals {
+				if tr.left == symbol && tr.terminal.Match(runes[start]) {
+					n.Families = append(n.Families, &gearley.Family{
+						Rule:     tr.rule,
+						Children: []*gearley.ForestNode{leaves[start]},
+					})
+				}
+			}
+		}
+		for mid := start + 1; mid < end; mid++ {
+			for _, br := range p.binaries {
+				if br.left == symbol && t.has(start, mid, br.one) && t.has(mid, end, br.two) {
+					n.Families = append(n.Families, &gearley.Family{
+						Rule:     br.rule,
+						Children: []*gearley.ForestNode{node(br.one, start, mid), node(br.two, mid, end)},
+					})
+				}
+			}
+		}
+		return n
+	}
+	return &gearley.Forest{Root: node(p.start, 0, len(runes))}, nil
+}
@@ -0,0 +1,98 @@
+package cyk
+
+import (
+	"testing"
+
+	"github.com/liuzl/gearley"
+	"github.com/liuzl/gearley/internal/differential"
+)
+
+// The differential tests run the same grammars through the CYK parser and
+// the Earley parser of package gearley, and check that both accept the same
+// inputs, with the same trees.
+
+func TestDifferential(t *testing.T) {
+	for name, bnf := range differential.Grammars() {
+		g, err := gearley.ParseBNF(bnf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := New(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		earley := gearley.NewEarleyParser(g)
+		inputs, err := differential.Inputs(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, input := range inputs {
+			if (p.Recognize(input) == nil) != (earley.Recognize(input) == nil) {
+				t.Errorf("%s: %q: cyk %v, earley %v", name, input, p.Recognize(input), earley.Recognize(input))
+				continue
+			}
+			forest, err := p.Parse(input)
+			if err != nil {
+				continue
+			}
+			ef, _ := earley.Parse(input)
+			want := map[string]bool{}
+			for _, tree := range ef.Trees(0) {
+				want[tree.String()] = true
+			}
+			trees := forest.Trees(50)
+			if len(trees) == 0 {
+				t.Errorf("%s: %q: no tree", name, input)
+			}
+			for _, tree := range trees {
+				restored, err := p.Restore(tree)
+				if err != nil {
+					t.Errorf("%s: %q: %v", name, input, err)
+				} else if !want[restored.String()] {
+					t.Errorf("%s: %q: %v is no tree of the earley parser", name, input, restored)
+				}
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	// a grammar in CNF already: both parsers have the same trees
+	g, _ := gearley.ParseBNF(differential.Grammars()["ambiguous"])
+	p, err := New(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	earley := gearley.NewEarleyParser(g)
+	for n := 1; n <= 8; n++ {
+		input := ""
+		for i := 0; i < n; i++ {
+			input += "a"
+		}
+		forest, err := p.Parse(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ef, _ := earley.Parse(input)
+		if forest.Count() != ef.Count() {
+			t.Errorf("%q: %d trees, earley has %d", input, forest.Count(), ef.Count())
+		}
+	}
+
+	g, _ = gearley.ParseBNF(`<S> ::= "a" <S> | ""`)
+	p, _ = New(g)
+	forest, err := p.Parse("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := p.Restore(forest.Trees(0)[0])
+	if err != nil || tree.String() != "(S)" {
+		t.Errorf("Restore = %v, %v", tree, err)
+	}
+	if err := p.Recognize("b"); err == nil || err.Error() != `cyk: "b" is not a sentence` {
+		t.Errorf("Recognize(b) = %v", err)
+	}
+	if _, err := New(gearley.NewGrammar(gearley.NewRule(gearley.NewNonTerminal("S"), gearley.NewNonTerminal("S")))); err == nil {
+		t.Errorf("New: no error for an empty language")
+	}
+}
@@ -0,0 +1,98 @@
+package earley3
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+/*
+ * the classic stress cases of Earley parsers, at several input lengths. each
+ * input is checked to parse, so that a broken parser does not pass for a fast
+ * one
+ */
+
+func benchmarkParse(b *testing.B, start *Rule, text func(n int) string, sizes ...int) {
+	g, err := Compile(start)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, n := range sizes {
+		in := text(n)
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			if err := g.Parse(in).Err(); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				g.Parse(in)
+			}
+		})
+	}
+}
+
+/*
+ * n tokens "a"
+ */
+func as(n int) string {
+	return strings.TrimSpace(strings.Repeat("a ", n))
+}
+
+/*
+ * S -> S S | a: a Catalan number of trees, and a cubic table
+ */
+func BenchmarkParseAmbiguous(b *testing.B) {
+	S := NewRule("S", NewProduction(&Terminal{"a"}))
+	S.add(NewProduction(S, S))
+	benchmarkParse(b, S, as, 25, 50, 100)
+}
+
+/*
+ * S -> S a | a: linear
+ */
+func BenchmarkParseLeft(b *testing.B) {
+	S := NewRule("S", NewProduction(&Terminal{"a"}))
+	S.add(NewProduction(S, &Terminal{"a"}))
+	benchmarkParse(b, S, as, 1000, 10000, 100000)
+}
+
+/*
+ * S -> a S | a: quadratic without Leo's optimization
+ */
+func BenchmarkParseRight(b *testing.B) {
+	S := NewRule("S", NewProduction(&Terminal{"a"}))
+	S.add(NewProduction(&Terminal{"a"}, S))
+	benchmarkParse(b, S, as, 100, 500, 1000)
+}
+
+/*
+ * S -> S X | ε, X -> O O O x, O -> o | ε: most rules derive the empty string
+ */
+func BenchmarkParseNullable(b *testing.B) {
+	O := NewRule("O", NewProduction(&Terminal{"o"}), NewProduction())
+	X := NewRule("X", NewProduction(O, O, O, &Terminal{"x"}))
+	S := NewRule("S", NewProduction())
+	S.add(NewProduction(S, X))
+	text := func(n int) string {
+		return strings.TrimSpace(strings.Repeat("o x ", n/2))
+	}
+	benchmarkParse(b, S, text, 1000, 10000, 100000)
+}
+
+/*
+ * arithmetic expressions with the usual precedence levels
+ */
+func BenchmarkParseArithmetic(b *testing.B) {
+	E := NewRule("E")
+	T := NewRule("T")
+	F := NewRule("F",
+		NewProduction(&Terminal{"("}, E, &Terminal{")"}),
+		NewProduction(TerminalFunc("number", isNumber)))
+	T.add(NewProduction(T, &Terminal{"*"}, F), NewProduction(F))
+	E.add(NewProduction(E, &Terminal{"+"}, T), NewProduction(T))
+	text := func(n int) string {
+		return "1" + strings.Repeat(" + ( 2 * 3 )", n/6)
+	}
+	benchmarkParse(b, E, text, 1000, 10000, 100000)
+}
@@ -0,0 +1,142 @@
+package earley3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/liuzl/gearley/internal/bnf"
+)
+
+/*
+ * The BNF form of a grammar writes rules in angle brackets, tokens as Go
+ * quoted strings, matchers in square brackets and the empty production as "":
+ *
+ *   <EXPR> ::= <SYM>
+ *            | <EXPR> "+" <EXPR>
+ *   <SYM> ::= "a"
+ *
+ * The first rule is the start rule. A backslash escapes a backslash or closing
+ * bracket in a name, and \n stands for a newline. Comments run from # to the
+ * end of the line.
+ */
+
+/*
+ * return the grammar made of the rules reachable from start in BNF. a rule
+ * without productions has no BNF: it derives nothing, where <R> ::= "" derives
+ * the empty sequence
+ */
+func FormatBNF(start *Rule) (string, error) {
+	rules, err := reachableRules(start)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, r := range rules {
+		if len(r.productions) == 0 {
+			return "", fmt.Errorf("earley3: bnf: rule <%s> has no productions", r.name)
+		}
+		head := bnf.Name('<', '>', r.name) + " ::= "
+		for i, prod := range r.productions {
+			if i == 0 {
+				b.WriteString(head)
+			} else {
+				b.WriteString(strings.Repeat(" ", len(head)-2) + "| ")
+			}
+			b.WriteString(bnfProduction(prod))
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
+
+func bnfProduction(prod *Production) string {
+	if prod.size() == 0 {
+		return `""`
+	}
+	parts := []string{}
+	for _, term := range prod.terms {
+		switch term := term.(type) {
+		case *Terminal:
+			parts = append(parts, strconv.Quote(term.value))
+		case *Matcher:
+			parts = append(parts, bnf.Name('[', ']', term.name))
+		case *Rule:
+			parts = append(parts, bnf.Name('<', '>', term.name))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+/*
+ * read a grammar written in BNF and return its start rule. the matchers it
+ * refers to are looked up by name in matchers
+ */
+func ParseBNF(text string, matchers ...*Matcher) (*Rule, error) {
+	tokens, err := bnf.Lex(text)
+	if err != nil {
+		return nil, fmt.Errorf("earley3: bnf %w", err)
+	}
+	byName := bnf.ByName(matchers, func(m *Matcher) string { return m.name })
+	rules := map[string]*Rule{}
+	getRule := func(name string) *Rule {
+		if _, ok := rules[name]; !ok {
+			rules[name] = NewRule(name)
+		}
+		return rules[name]
+	}
+	defined := map[string]bool{}
+	var start *Rule
+	i := 0
+	for i < len(tokens) {
+		if tokens[i].Kind != bnf.NonTerminal || i+1 == len(tokens) || tokens[i+1].Kind != bnf.Define {
+			return nil, fmt.Errorf("earley3: bnf line %d: expected <name> ::=", tokens[i].Line)
+		}
+		rule := getRule(tokens[i].Text)
+		defined[rule.name] = true
+		if start == nil {
+			start = rule
+		}
+		i += 2
+		terms := []interface{}{}
+		for {
+			if i == len(tokens) || tokens[i].Kind == bnf.Bar ||
+				(tokens[i].Kind == bnf.NonTerminal && i+1 < len(tokens) && tokens[i+1].Kind == bnf.Define) {
+				rule.add(NewProduction(terms...))
+				terms = []interface{}{}
+				if i < len(tokens) && tokens[i].Kind == bnf.Bar {
+					i++
+					continue
+				}
+				break
+			}
+			t := tokens[i]
+			switch t.Kind {
+			case bnf.NonTerminal:
+				terms = append(terms, getRule(t.Text))
+			case bnf.String:
+				if t.Text != "" {
+					terms = append(terms, &Terminal{t.Text})
+				}
+			case bnf.Matcher:
+				m, ok := byName[t.Text]
+				if !ok {
+					return nil, fmt.Errorf("earley3: bnf line %d: unknown matcher %q", t.Line, t.Text)
+				}
+				terms = append(terms, m)
+			default:
+				return nil, fmt.Errorf("earley3: bnf line %d: unexpected ::=", t.Line)
+			}
+			i++
+		}
+	}
+	if start == nil {
+		return nil, fmt.Errorf("earley3: bnf: no rules")
+	}
+	for name := range rules {
+		if !defined[name] {
+			return nil, fmt.Errorf("earley3: bnf: undefined rule <%s>", name)
+		}
+	}
+	return start, nil
+}
@@ -0,0 +1,74 @@
+package earley3
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/liuzl/gearley"
+	"github.com/liuzl/gearley/internal/differential"
+	"github.com/liuzl/gearley/semiring"
+)
+
+/*
+ * The differential tests run the same grammars through this package and the
+ * rune parser of package gearley, and check that both agree on which inputs
+ * they accept and on how many trees each input has. A rune terminal 'a' is the
+ * token "a" here, so the inputs are the same once their runes are spaced out.
+ */
+
+func TestDifferential(t *testing.T) {
+	for name, text := range differential.Grammars() {
+		t.Run(name, func(t *testing.T) {
+			g, err := gearley.ParseBNF(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start, err := ParseBNF(g.BNF())
+			if err != nil {
+				t.Fatal(err)
+			}
+			compiled, err := Compile(start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs, err := differential.Inputs(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, input := range inputs {
+				checkAgree(t, g, compiled, []rune(input))
+			}
+		})
+	}
+}
+
+/*
+ * check that both parsers agree on input
+ */
+func checkAgree(t *testing.T, g *gearley.Grammar, compiled *Grammar, input []rune) {
+	t.Helper()
+	tokens := make([]string, len(input))
+	for i, r := range input {
+		tokens[i] = string(r)
+	}
+	text := strings.Join(tokens, " ")
+
+	p := compiled.Parse(text)
+	accepted := p.Err() == nil
+	if want := g.Parse(string(input)) == nil; accepted != want {
+		t.Errorf("%q: accepted %v, gearley %v", text, accepted, want)
+		return
+	}
+	want, _ := gearley.Score[uint64](g, string(input), semiring.Counting{},
+		func(*gearley.Rule) uint64 { return 1 })
+	got := Score[uint64](p, semiring.Counting{},
+		func(*Production) uint64 { return 1 })
+	if got != want {
+		t.Errorf("%q: score %d trees, gearley %d", text, got, want)
+	}
+	if accepted {
+		if trees := p.Trees(); uint64(len(trees)) != want {
+			t.Errorf("%q: %d trees, gearley %d", text, len(trees), want)
+		}
+	}
+}
@@ -1,15 +1,12 @@
 package earley3
 
 import (
+	"context"
 	"fmt"
-	"github.com/golang/glog"
-	"os"
-	"reflect"
-	"strings"
-)
+	"io"
 
-const debug = false
-const treeDebug = false
+	"github.com/liuzl/gearley/trace"
+)
 
 /*
  * Terminology
@@ -35,6 +32,40 @@ func (self *Terminal) String() string {
 	return self.value
 }
 
+/*
+ * Represents a terminal element matching every token its predicate accepts,
+ * such as "any number" or "a word in this dictionary"
+ */
+type Matcher struct {
+	name  string
+	match func(string) bool
+}
+
+/*
+ * return a terminal matching the tokens accepted by match. name stands for the
+ * terminal in grammar listings and error messages
+ */
+func TerminalFunc(name string, match func(string) bool) *Matcher {
+	return &Matcher{name: name, match: match}
+}
+
+func (self *Matcher) String() string {
+	return "[" + self.name + "]"
+}
+
+/*
+ * whether the terminal term (a *Terminal or a *Matcher) matches token
+ */
+func matchesToken(term interface{}, token string) bool {
+	switch t := term.(type) {
+	case *Terminal:
+		return t.value == token
+	case *Matcher:
+		return t.match(token)
+	}
+	return false
+}
+
 /*
  * Represents a production of the rule.
  */
@@ -43,6 +74,11 @@ type Production struct {
 	rules []*Rule
 }
 
+/*
+ * return the production of terms, each a *Terminal, a *Matcher, a *Rule or a
+ * string standing for a *Terminal. it panics on a term of any other type: a
+ * production without it would be another one
+ */
 func NewProduction(terms ...interface{}) *Production {
 	prod := &Production{}
 	for _, term := range terms {
@@ -51,10 +87,12 @@ func NewProduction(terms ...interface{}) *Production {
 			prod.terms = append(prod.terms, term.(*Terminal))
 		case string: // treat string as Terminal
 			prod.terms = append(prod.terms, &Terminal{term.(string)})
+		case *Matcher:
+			prod.terms = append(prod.terms, term.(*Matcher))
 		case *Rule:
 			prod.terms = append(prod.terms, term.(*Rule))
 		default:
-			println("*Terminal, *Rule, string, not " + reflect.TypeOf(term).String())
+			panic(fmt.Sprintf("earley3: NewProduction: a term is a *Terminal, *Matcher, *Rule or string, not %T", term))
 		}
 	}
 	prod.getRules()
@@ -85,6 +123,8 @@ func (self *Production) String() string {
 		switch term.(type) {
 		case *Terminal:
 			s += term.(*Terminal).value
+		case *Matcher:
+			s += term.(*Matcher).String()
 		case *Rule:
 			s += term.(*Rule).name
 		}
@@ -118,6 +158,9 @@ var Epsilon = Production{}
 type Rule struct {
 	name        string
 	productions []*Production
+	// how the nodes of the rule are shaped in trees, see shape.go
+	shape Shape
+	alias string
 }
 
 func NewRule(name string, prods ...*Production) *Rule {
@@ -154,6 +197,7 @@ func (self *Rule) String() string {
  */
 type TableState struct {
 	name       string
+	rule       *Rule
 	production *Production
 	dotIndex   int
 	startCol   *TableColumn
@@ -180,6 +224,8 @@ func (self *TableState) String() string {
 		switch term.(type) {
 		case *Terminal:
 			s += term.(*Terminal).value
+		case *Matcher:
+			s += term.(*Matcher).String()
 		case *Rule:
 			s += term.(*Rule).name
 		}
@@ -196,27 +242,65 @@ func (self *TableState) String() string {
  * Represents a column in the Earley parsing table
  */
 type TableColumn struct {
+	// the token ending at the column, the tokens of the edges ending at it
+	// joined by | for a lattice
 	token  string
 	index  int
 	states []*TableState
+	// the edges of the tokens ending at the column and leaving it, by index
+	// in the parser's edges
+	in, out []int
+	// the states are allocated stateChunk at a time from arena, and indexed
+	// by their [production, dotIndex, startCol]
+	arena []TableState
+	byKey map[stateKey]*TableState
+}
+
+/*
+ * the states of a column are told apart by their rule's name, production,
+ * dot-location and starting column: rules may share a production, such as
+ * &Epsilon
+ */
+type stateKey struct {
+	name       string
+	production *Production
+	dotIndex   int
+	startCol   *TableColumn
 }
 
+const stateChunk = 64
+
 /*
  * only insert a state if it is not already contained in the list of states.
- * return the inserted state, or the pre-existing one.
+ * return the state in the column, and whether it was inserted.
  */
-func (self *TableColumn) insert(state *TableState) *TableState {
-	state.endCol = self
-	for _, s := range self.states {
-		if *state == *s {
-			return s
-		}
+func (self *TableColumn) insert(state TableState) (*TableState, bool) {
+	key := stateKey{state.name, state.production, state.dotIndex, state.startCol}
+	if s, ok := self.byKey[key]; ok {
+		return s, false
+	}
+	if self.byKey == nil {
+		self.byKey = map[stateKey]*TableState{}
 	}
-	self.states = append(self.states, state)
-	if debug {
-		glog.Infof("        <inserted> %+v", state)
+	if len(self.arena) == cap(self.arena) {
+		// a new chunk: the states handed out so far stay where they are
+		self.arena = make([]TableState, 0, stateChunk)
 	}
-	return self.get(self.size() - 1)
+	state.endCol = self
+	self.arena = append(self.arena, state)
+	s := &self.arena[len(self.arena)-1]
+	self.byKey[key] = s
+	self.states = append(self.states, s)
+	return s, true
+}
+
+/*
+ * whether the column holds the state [name, production, dotIndex, startCol]
+ */
+func (self *TableColumn) contains(name string, prod *Production, dotIndex int,
+	startCol *TableColumn) bool {
+	_, ok := self.byKey[stateKey{name, prod, dotIndex, startCol}]
+	return ok
 }
 
 func (self *TableColumn) size() int {
@@ -237,7 +321,7 @@ func (self *TableColumn) String() string {
 	return out
 }
 
-func (self *TableColumn) Print(out *os.File, showUncompleted bool) {
+func (self *TableColumn) Print(out io.Writer, showUncompleted bool) {
 	fmt.Fprintf(out, "[%d] '%s'\n", self.index, self.token)
 	fmt.Fprintln(out, "=======================================")
 	for _, s := range self.states {
@@ -250,18 +334,83 @@ func (self *TableColumn) Print(out *os.File, showUncompleted bool) {
 }
 
 /*
- * A generic tree node
+ * A node of a parse tree: a rule spanning some tokens, with a child per term of
+ * its production, or a token
  */
 type Node struct {
+	// a *TableState, or the tokenLeaf of a token. decoded trees have a
+	// symbolNode in place of the *TableState
 	value    interface{}
 	children []*Node
+	// decoded from brackets, see decodeBrackets: the spans number the tokens
+	// of the tree
+	bracketed bool
 }
 
-func (self *Node) Print(out *os.File) {
+/*
+ * the value of the node of a token: the token, and the columns it spans
+ */
+type tokenLeaf struct {
+	token      string
+	start, end int
+}
+
+func (self tokenLeaf) String() string {
+	return self.token
+}
+
+/*
+ * the name of the rule of the node, "" for a token. the root of a tree is the
+ * gamma rule
+ */
+func (self *Node) Symbol() string {
+	switch value := self.value.(type) {
+	case *TableState:
+		return value.name
+	case symbolNode:
+		return value.name
+	}
+	return ""
+}
+
+/*
+ * the token of the node, "" for a rule
+ */
+func (self *Node) Token() string {
+	if leaf, ok := self.value.(tokenLeaf); ok {
+		return leaf.token
+	}
+	return ""
+}
+
+/*
+ * the children of the node, in order
+ */
+func (self *Node) Children() []*Node {
+	return append([]*Node(nil), self.children...)
+}
+
+/*
+ * the tokens the node spans, from start (included) to end (excluded), counted
+ * from 0
+ */
+func (self *Node) Span() (start, end int) {
+	switch value := self.value.(type) {
+	case *TableState:
+		return value.startCol.index, value.endCol.index
+	case symbolNode:
+		return value.start, value.end
+	case tokenLeaf:
+		return value.start, value.end
+	}
+	return 0, 0
+}
+
+func (self *Node) Print(out io.Writer) {
 	self.PrintLevel(out, 0)
 }
 
-func (self *Node) PrintLevel(out *os.File, level int) {
+func (self *Node) PrintLevel(out io.Writer, level int) {
 	indentation := ""
 	for i := 0; i < level; i++ {
 		indentation += "  "
@@ -273,11 +422,7 @@ func (self *Node) PrintLevel(out *os.File, level int) {
 }
 
 func (self *Node) String() string {
-	if len(self.children) > 0 {
-		return fmt.Sprintf("%+v %+v", self.value, self.children)
-	} else {
-		return fmt.Sprintf("%+v", self.value)
-	}
+	return self.SExpr()
 }
 
 /*
@@ -286,14 +431,28 @@ func (self *Node) String() string {
  * Usage:
  *
  *   var p *Parser = NewParser(StartRule, "my space-delimited statement")
- *   for _, tree := range p.getTrees() {
+ *   for _, tree := range p.Trees() {
  *     tree.Print(os.Stdout)
  *   }
  *
  */
 type Parser struct {
-	columns    []*TableColumn
+	columns []*TableColumn
+	// the tokens of the input, as edges between the columns
+	edges      []Edge
 	finalState *TableState
+	tracer     trace.Tracer
+	cfg        *config
+	// the limit that stopped the parse, if any
+	err error
+	// the limits of the trees being built
+	trees *treeLimits
+	// the left corner tables, and the terminals matching the token after
+	// the column being filled
+	corners   *leftCorners
+	lookahead bitset
+	// the states scanned into the columns after the one being filled
+	ahead int
 }
 
 func (self *Parser) String() string {
@@ -304,16 +463,12 @@ func (self *Parser) String() string {
 	return out
 }
 
-func NewParser(startRule *Rule, text string) *Parser {
-	tokens := strings.Fields(text)
-	parser := &Parser{}
-	parser.columns = append(parser.columns, &TableColumn{index: 0, token: ""})
-	for i, token := range tokens {
-		parser.columns = append(parser.columns,
-			&TableColumn{index: i + 1, token: token})
-	}
-	parser.finalState = parser.parse(startRule)
-	return parser
+func NewParser(startRule *Rule, text string, opts ...Option) *Parser {
+	return newParser(context.Background(), startRule, text, newConfig(opts))
+}
+
+func newParser(ctx context.Context, startRule *Rule, text string, cfg *config) *Parser {
+	return newLatticeParser(ctx, startRule, textLattice(text), cfg)
 }
 
 // this is the name of the special "gamma" rule added by the algorithm
@@ -326,41 +481,57 @@ const GAMMA_RULE = "\u0263" // "\u0194"
  * state, or null, if the parse failed.
  */
 func (self *Parser) parse(startRule *Rule) *TableState {
-	begin := TableState{
+	begin, _ := self.columns[0].insert(TableState{
 		name:       GAMMA_RULE,
 		production: NewProduction(startRule),
 		dotIndex:   0,
-		startCol:   self.columns[0],
-		endCol:     self.columns[0]}
-
-	self.columns[0].states = append(self.columns[0].states, &begin)
+		startCol:   self.columns[0]})
+	self.tracer.OnPredict(begin, 0)
 
+	// states in the columns before the current one
+	doneStates := 0
 	for i, col := range self.columns {
-		if debug {
-			glog.Infof("column: %d\n", i)
+		if i > 0 {
+			self.ahead -= col.size()
 		}
-		for j := 0; j < len(col.states); j++ {
-			state := col.states[j]
-			if debug {
-				glog.Infof("  process %d of %d, %+v ", j+1, len(col.states), state)
-			}
-			if state.isCompleted() {
-				self.complete(col, state)
-			} else {
-				var term interface{} = state.getNextTerm()
-				switch term.(type) {
-				case *Rule:
-					self.predict(col, term.(*Rule))
-				case *Terminal:
-					if i+1 < len(self.columns) {
-						self.scan(self.columns[i+1], state, term.(*Terminal).value)
+		self.lookahead = self.corners.lookahead("", true)
+		for _, e := range col.out {
+			self.lookahead.union(self.corners.lookahead(self.edges[e].Token, false))
+		}
+		for _, r := range self.cfg.seeds {
+			self.predict(col, r)
+		}
+		j := 0
+		for {
+			for ; j < len(col.states); j++ {
+				if self.err = self.check(i, j, doneStates); self.err != nil {
+					return nil
+				}
+				state := col.states[j]
+				if state.isCompleted() {
+					self.complete(col, state)
+				} else {
+					var term interface{} = state.getNextTerm()
+					switch term.(type) {
+					case *Rule:
+						self.predict(col, term.(*Rule))
+					case *Terminal, *Matcher:
+						self.scan(col, state, term)
 					}
 				}
 			}
+			self.handleEpsilons(col)
+			if j == len(col.states) {
+				break
+			}
+			// the epsilons moved dots over nullable rules: the new states may
+			// scan the next token
 		}
-		self.handleEpsilons(col)
-		// DEBUG -- uncomment to print the table during parsing, column after column
-		//col.Print(os.Stdout, true)
+		if self.err = self.check(i, 0, doneStates); self.err != nil {
+			return nil
+		}
+		self.tracer.OnSetDone(col.index, col.size())
+		doneStates += col.size()
 	}
 
 	// find end state (return nil if not found)
@@ -374,30 +545,39 @@ func (self *Parser) parse(startRule *Rule) *TableState {
 }
 
 /*
- * Earley scan
+ * Earley scan, along every edge leaving col whose token matches term
  */
-func (self *Parser) scan(col *TableColumn, st *TableState, token string) {
-	if debug {
-		glog.Infof("    <scan> TermInProduction: %s, Input: %s\n", token, col.token)
-	}
-	if token == col.token {
-		col.insert(&TableState{name: st.name, production: st.production,
-			dotIndex: st.dotIndex + 1, startCol: st.startCol})
+func (self *Parser) scan(col *TableColumn, st *TableState, term interface{}) {
+	for _, e := range col.out {
+		edge := &self.edges[e]
+		if !matchesToken(term, edge.Token) {
+			continue
+		}
+		st1, inserted := self.columns[edge.To].insert(TableState{name: st.name, rule: st.rule,
+			production: st.production, dotIndex: st.dotIndex + 1, startCol: st.startCol})
+		if inserted {
+			self.tracer.OnScan(st1, edge.To)
+			self.ahead++
+		}
 	}
 }
 
 /*
- * Earley predict. returns true if the table has been changed, false otherwise
+ * Earley predict, of the productions that may start with the lookahead.
+ * returns true if the table has been changed, false otherwise
  */
 func (self *Parser) predict(col *TableColumn, r *Rule) bool {
-	if debug {
-		glog.Infof("    <predict> %s\n", r.name)
-	}
 	changed := false
 	for _, prod := range r.productions {
-		st := &TableState{name: r.name, production: prod, dotIndex: 0, startCol: col}
-		st2 := col.insert(st)
-		changed = changed || (st == st2)
+		if !self.corners.predicts(prod, self.lookahead) {
+			continue
+		}
+		st, inserted := col.insert(TableState{name: r.name, rule: r, production: prod,
+			dotIndex: 0, startCol: col})
+		if inserted {
+			self.tracer.OnPredict(st, col.index)
+			changed = true
+		}
 	}
 	return changed
 }
@@ -406,20 +586,16 @@ func (self *Parser) predict(col *TableColumn, r *Rule) bool {
  * Earley complete. returns true if the table has been changed, false otherwise
  */
 func (self *Parser) complete(col *TableColumn, state *TableState) bool {
-	if debug {
-		glog.Infof("    <complete> %+v\n", state)
-	}
 	changed := false
 	for _, st := range state.startCol.states {
 		var term interface{} = st.getNextTerm()
 		if r, ok := term.(*Rule); ok && r.name == state.name {
-			if debug {
-				glog.Infof("      %+v {%+v}\n", st, term)
+			st1, inserted := col.insert(TableState{name: st.name, rule: st.rule, production: st.production,
+				dotIndex: st.dotIndex + 1, startCol: st.startCol})
+			if inserted {
+				self.tracer.OnComplete(st1, col.index)
+				changed = true
 			}
-			st1 := &TableState{name: st.name, production: st.production,
-				dotIndex: st.dotIndex + 1, startCol: st.startCol, endCol: col}
-			st2 := col.insert(st1)
-			changed = changed || (st1 == st2)
 		}
 	}
 	return changed
@@ -446,15 +622,15 @@ func (self *Parser) handleEpsilons(col *TableColumn) {
 }
 
 /*
- * return all parse trees (forest). the forest is simply a list of root nodes, each
- * representing a possible parse tree. a node is contains a value and the node's
- * children, and supports pretty-printing
+ * return all parse trees (forest), nil if the parse failed. the forest is simply
+ * a list of root nodes, each representing a possible parse tree. a node is
+ * contains a value and the node's children, and supports pretty-printing. when
+ * the parse has more trees than the limit set by WithMaxTrees, return that
+ * many: TreesContext reports the cut
  */
-func (self *Parser) getTrees() *[]*Node {
-	if self.finalState != nil {
-		return self.buildTrees(self.finalState)
-	}
-	return nil
+func (self *Parser) Trees() []*Node {
+	trees, _ := self.TreesContext(context.Background())
+	return trees
 }
 
 /*
@@ -475,49 +651,52 @@ func (self *Parser) getTrees() *[]*Node {
  *    W -> M1
  *
  */
-
-var seq = 0
-
-func blanks(seq int) string {
-	s := ""
-	for i := 0; i < seq; i++ {
-		s += "  "
-	}
-	return s
-}
-
 func (self *Parser) buildTrees(state *TableState) *[]*Node {
-	if treeDebug {
-		glog.Infof("%s<buildTree> state:%+v", blanks(seq), state)
-	}
+	self.tracer.OnTreeBuild(state, state.endCol.index)
 	return self.buildTreesHelper(
-		&[]*Node{}, state, len(state.production.rules)-1, state.endCol)
+		&[]*Node{}, state, state.dotIndex-1, state.endCol)
 }
 
 func (self *Parser) buildTreesHelper(children *[]*Node, state *TableState,
-	ruleIndex int, endCol *TableColumn) *[]*Node {
-	// begin with the last --non-terminal-- of the production of finalState
-	//var outputs []*Node
+	termIndex int, endCol *TableColumn) *[]*Node {
+	// begin with the last term of the production of finalState
 	outputs := &[]*Node{}
-	if treeDebug {
-		seq += 1
-		glog.Infof("%s%d <buildTreeHelper> children:%+v, state:%+v, ruleIndex:%+v, endCol:%d",
-			blanks(seq), seq, children, state, ruleIndex, endCol.index)
-		defer glog.Infof("%s%d return: %+v", blanks(seq), seq, outputs)
+	if self.trees.stop() {
+		return outputs
 	}
-	var startCol *TableColumn
-	if ruleIndex < 0 {
+	if termIndex < 0 {
 		// this is the base-case for the recursion (we matched the entire rule)
-		*outputs = append(*outputs, &Node{value: state, children: *children})
-		if treeDebug {
-			glog.Infof("%sreturn here", blanks(seq))
+		if endCol == state.startCol {
+			*outputs = append(*outputs, &Node{value: state, children: *children})
 		}
 		return outputs
-	} else if ruleIndex == 0 {
-		// if this is the first rule
+	}
+	var startCol *TableColumn
+	if termIndex == 0 {
+		// if this is the first term
 		startCol = state.startCol
 	}
-	rule := state.production.rules[ruleIndex]
+	term := state.production.get(termIndex)
+	rule, ok := term.(*Rule)
+	if !ok {
+		// a token: it has to be one of the edges ending at endCol
+		for _, e := range endCol.in {
+			edge := &self.edges[e]
+			if edge.From < state.startCol.index || !matchesToken(term, edge.Token) {
+				continue
+			}
+			children2 := []*Node{{value: tokenLeaf{edge.Token, edge.From, edge.To}}}
+			children2 = append(children2, *children...)
+			for _, node := range *self.buildTreesHelper(
+				&children2, state, termIndex-1, self.columns[edge.From]) {
+				if !self.trees.allow(len(*outputs)) {
+					return outputs
+				}
+				*outputs = append(*outputs, node)
+			}
+		}
+		return outputs
+	}
 
 	for _, st := range endCol.states {
 		if st == state {
@@ -535,6 +714,9 @@ func (self *Parser) buildTreesHelper(children *[]*Node, state *TableState,
 			// if startCol isn't nil, this state must span from startCol to endCol
 			continue
 		}
+		if st.startCol.index < state.startCol.index {
+			continue
+		}
 		// okay, so `st` matches -- now we need to create a tree for every possible
 		// sub-match
 		for _, subTree := range *self.buildTrees(st) {
@@ -544,7 +726,10 @@ func (self *Parser) buildTreesHelper(children *[]*Node, state *TableState,
 			children2 = append(children2, *children...)
 			// now try all options
 			for _, node := range *self.buildTreesHelper(
-				&children2, state, ruleIndex-1, st.startCol) {
+				&children2, state, termIndex-1, st.startCol) {
+				if !self.trees.allow(len(*outputs)) {
+					return outputs
+				}
 				*outputs = append(*outputs, node)
 			}
 		}
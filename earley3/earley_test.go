@@ -1,8 +1,16 @@
 package earley3
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/liuzl/gearley/semiring"
+	"github.com/liuzl/gearley/trace"
 )
 
 func TestEarleyParse(t *testing.T) {
@@ -11,22 +19,400 @@ func TestEarleyParse(t *testing.T) {
 	EXPR := NewRule("EXPR", NewProduction(SYM))
 	EXPR.add(NewProduction(EXPR, OP, EXPR))
 
-	strs := []string{
-		//"a",
-		"a + a",
-		"a + a + a",
-		//"a + a + a + a",
-		//"a + a + a + a + a",
-		//"a + a + a + a + a + a",
-		"a + a + a + a + a + a + a",
+	// the number of trees of n operands is the Catalan number C(n-1)
+	strs := map[string]int{
+		"a":                         1,
+		"a + a":                     1,
+		"a + a + a":                 2,
+		"a + a + a + a":             5,
+		"a + a + a + a + a + a + a": 132,
+	}
+	for text, want := range strs {
+		p := NewParser(EXPR, text)
+		trees := p.Trees()
+		if len(trees) != want {
+			t.Errorf("%q: %d trees, want %d", text, len(trees), want)
+		}
+	}
+}
+
+func TestTrees(t *testing.T) {
+	NUM := NewRule("NUM", NewProduction(TerminalFunc("number", isNumber)))
+	SUM := NewRule("SUM", NewProduction(NUM))
+	SUM.add(NewProduction(SUM, "+", NUM))
+
+	if trees := NewParser(SUM, "1 +").Trees(); trees != nil {
+		t.Errorf("Trees() = %v, want nil", trees)
+	}
+	trees := NewParser(SUM, "1 + 22").Trees()
+	if len(trees) != 1 {
+		t.Fatalf("%d trees, want 1", len(trees))
+	}
+	sum := trees[0].Children()[0]
+	if start, end := sum.Span(); sum.Symbol() != "SUM" || start != 0 || end != 3 {
+		t.Errorf("root child = %s [%d-%d]", sum.Symbol(), start, end)
+	}
+	children := sum.Children()
+	if len(children) != 3 {
+		t.Fatalf("%d children, want 3", len(children))
+	}
+	plus := children[1]
+	if start, end := plus.Span(); plus.Symbol() != "" || plus.Token() != "+" || start != 1 || end != 2 {
+		t.Errorf("token = %q %q [%d-%d]", plus.Symbol(), plus.Token(), start, end)
+	}
+	if num := children[2]; num.Symbol() != "NUM" || num.Token() != "" || num.Children()[0].Token() != "22" {
+		t.Errorf("NUM = %v", num)
+	}
+
+	var b strings.Builder
+	trees[0].Print(&b)
+	want := "\u0263 -> SUM \u00B7 [0-3]\n" +
+		"  SUM -> SUM + NUM \u00B7 [0-3]\n" +
+		"    SUM -> NUM \u00B7 [0-1]\n" +
+		"      NUM -> [number] \u00B7 [0-1]\n" +
+		"        1\n" +
+		"    +\n" +
+		"    NUM -> [number] \u00B7 [2-3]\n" +
+		"      22\n"
+	if b.String() != want {
+		t.Errorf("Print() =\n%s\nwant\n%s", b.String(), want)
 	}
-	for _, text := range strs {
+}
+
+func TestScore(t *testing.T) {
+	SYM := NewRule("SYM", NewProduction(&Terminal{"a"}))
+	OP := NewRule("OP", NewProduction(&Terminal{"+"}))
+	EXPR := NewRule("EXPR", NewProduction(SYM))
+	EXPR.add(NewProduction(EXPR, OP, EXPR))
+
+	one := func(*Production) uint64 { return 1 }
+	for text, want := range map[string]uint64{
+		"a":             1,
+		"a + a":         1,
+		"a + a + a":     2,
+		"a + a + a + a": 5,
+		"a +":           0,
+	} {
 		p := NewParser(EXPR, text)
-		trees := p.getTrees()
-		t.Log("tree number:", len(*trees))
-		for _, tree := range *trees {
-			tree.Print(os.Stdout)
+		if got := Score[uint64](p, semiring.Counting{}, one); got != want {
+			t.Errorf("count %q = %d, want %d", text, got, want)
+		}
+		if got := Score[bool](p, semiring.Boolean{}, func(*Production) bool { return true }); got != (want > 0) {
+			t.Errorf("recognise %q = %v", text, got)
+		}
+		if want > 0 && uint64(len(p.Trees())) != want {
+			t.Errorf("trees %q = %d, want %d", text, len(p.Trees()), want)
+		}
+	}
+
+	// terminals inside a production
+	SUM := NewRule("SUM", NewProduction("a"))
+	SUM.add(NewProduction(SUM, "+", SUM))
+	if got := Score[uint64](NewParser(SUM, "a + a + a"), semiring.Counting{}, one); got != 2 {
+		t.Errorf("count = %d, want 2", got)
+	}
+}
+
+func isNumber(token string) bool {
+	_, err := strconv.Atoi(token)
+	return err == nil
+}
+
+func TestTerminalFunc(t *testing.T) {
+	NUM := NewRule("NUM", NewProduction(TerminalFunc("number", isNumber)))
+	SUM := NewRule("SUM", NewProduction(NUM))
+	SUM.add(NewProduction(SUM, "+", NUM))
+
+	if got, want := SUM.String(), "SUM -> NUM | SUM + NUM"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got, want := NUM.String(), "NUM -> [number]"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if err := NewParser(SUM, "1 + 22 + 333").Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+	for text, want := range map[string]string{
+		"1 + x":   `unexpected "x" at 2, expected [number]`,
+		"1 1":     `unexpected "1" at 1, expected +`,
+		"1 + 2 +": "unexpected end of input at 4, expected [number]",
+	} {
+		if err := NewParser(SUM, text).Err(); err == nil || err.Error() != want {
+			t.Errorf("Err(%q) = %v, want %v", text, err, want)
+		}
+	}
+}
+
+func TestVisualize(t *testing.T) {
+	SUM := NewRule("SUM", NewProduction("a"))
+	SUM.add(NewProduction(SUM, "+", SUM))
+	p := NewParser(SUM, "a + a + a")
+
+	var b strings.Builder
+	if err := p.WriteChartHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<th>[0] </th><th>[1] a</th><th>[2] +</th>",
+		`<td class="completed">SUM -&gt; a ` + "·" + ` [0-1]</td>`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("chart: missing %s in\n%s", want, b.String())
 		}
-		//(*trees)[0].Print(os.Stdout)
 	}
+
+	SYM := NewRule("SYM", NewProduction(&Terminal{"a"}))
+	OP := NewRule("OP", NewProduction(&Terminal{"+"}))
+	EXPR := NewRule("EXPR", NewProduction(SYM))
+	EXPR.add(NewProduction(EXPR, OP, EXPR))
+	b.Reset()
+	if err := NewParser(EXPR, "a + a").Trees()[0].WriteDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	if want := `digraph tree {
+  n0 [label="ɣ [0-3]"];
+  n1 [label="EXPR [0-3]"];
+  n2 [label="EXPR [0-1]"];
+  n3 [label="SYM [0-1]"];
+  n4 [shape=box, label="a"];
+  n3 -> n4;
+  n2 -> n3;
+  n1 -> n2;
+  n5 [label="OP [1-2]"];
+  n6 [shape=box, label="+"];
+  n5 -> n6;
+  n1 -> n5;
+  n7 [label="EXPR [2-3]"];
+  n8 [label="SYM [2-3]"];
+  n9 [shape=box, label="a"];
+  n8 -> n9;
+  n7 -> n8;
+  n1 -> n7;
+  n0 -> n1;
+}
+`; b.String() != want {
+		t.Errorf("tree:\n%s", b.String())
+	}
+
+	b.Reset()
+	if err := p.WriteForestDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"SUM_0_5" [label="SUM [0-5]", color=red, fontcolor=red, penwidth=2];`,
+		`"SUM_0_5/0" [shape=diamond, color=red, label="SUM + SUM"];`,
+		`"SUM_0_5/0" -> t4;`,
+		`"SUM_0_3" [label="SUM [0-3]"];`,
+		`"SUM_0_3" -> "SUM_0_1";`,
+		`t5 [shape=box, label="a"];`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("forest: missing %s in\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), `"SUM_0_3/`) {
+		t.Errorf("forest: unambiguous node packed\n%s", b.String())
+	}
+}
+
+func TestWithTracer(t *testing.T) {
+	SUM := NewRule("SUM", NewProduction("a"))
+	SUM.add(NewProduction(SUM, "+", SUM))
+
+	var b strings.Builder
+	p := NewParser(SUM, "a + a", WithTracer(trace.NewText(&b)))
+	p.Trees()
+	for _, want := range []string{
+		"predict  S(0) ɣ -> ·SUM  [0-0]\n",
+		"scan     S(1) SUM -> a · [0-1]\n",
+		"complete S(1) SUM -> SUM ·+ SUM  [0-1]\n",
+		"done     S(3) 5 items\n",
+		"tree     S(3) ɣ -> SUM · [0-3]\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in\n%s", want, b.String())
+		}
+	}
+}
+
+func TestGrammarConcurrent(t *testing.T) {
+	SYM := NewRule("SYM", NewProduction(&Terminal{"a"}))
+	OP := NewRule("OP", NewProduction(&Terminal{"+"}))
+	EXPR := NewRule("EXPR", NewProduction(SYM))
+	EXPR.add(NewProduction(EXPR, OP, EXPR))
+	g, err := Compile(EXPR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// later changes to the rules do not reach the compiled grammar
+	OP.add(NewProduction(&Terminal{"-"}))
+
+	catalan := []int{1, 1, 2, 5, 14, 42}
+	var wg sync.WaitGroup
+	errs := make(chan string, 300)
+	for i := 0; i < 300; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			text := strings.TrimSuffix(strings.Repeat("a + ", n), " + ")
+			p := g.Parse(text)
+			if got := len(p.Trees()); got != catalan[n-1] {
+				errs <- fmt.Sprintf("trees %q = %d, want %d", text, got, catalan[n-1])
+			}
+			if g.Parse("a - a").Err() == nil {
+				errs <- "a - a accepted"
+			}
+		}(i%len(catalan) + 1)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if NewParser(EXPR, "a - a").Err() != nil {
+		t.Errorf("the rules did not change")
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	S := NewRule("S", NewProduction("a"))
+	S.add(NewProduction(S, S))
+	g, _ := Compile(S)
+	text := strings.TrimSpace(strings.Repeat("a ", 12))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p, err := g.ParseContext(ctx, text)
+	var limit *LimitError
+	if !errors.As(err, &limit) || limit.Limit != LimitContext || !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: %v", err)
+	}
+	if p.Err() != err {
+		t.Errorf("Err() = %v, want %v", p.Err(), err)
+	}
+
+	_, err = g.ParseContext(context.Background(), text, WithMaxItemsPerSet(10))
+	if !errors.As(err, &limit) || limit.Limit != LimitItemsPerSet || limit.Pos == 0 {
+		t.Errorf("items per set: %#v", err)
+	}
+	_, err = g.ParseContext(context.Background(), text, WithMaxChartSize(100))
+	if !errors.As(err, &limit) || limit.Limit != LimitChartSize || limit.Items <= 100 {
+		t.Errorf("chart size: %#v", err)
+	}
+
+	// C(11) = 58786 trees
+	p, err = g.ParseContext(context.Background(), text, WithMaxTrees(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trees, err := p.TreesContext(context.Background())
+	if len(trees) != 10 || !errors.As(err, &limit) || limit.Limit != LimitTrees {
+		t.Errorf("trees: %d, %v", len(trees), err)
+	}
+	if len(p.Trees()) != 10 {
+		t.Errorf("Trees: %d trees", len(p.Trees()))
+	}
+	trees, err = p.TreesContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("trees canceled: %d, %v", len(trees), err)
+	}
+}
+
+func TestExpected(t *testing.T) {
+	NUM := NewRule("NUM", NewProduction(TerminalFunc("number", isNumber)))
+	SUM := NewRule("SUM", NewProduction(NUM))
+	SUM.add(NewProduction(SUM, "+", NUM))
+	for text, want := range map[string]string{
+		"":      "[[number]]",
+		"1":     "[+]",
+		"1 +":   "[[number]]",
+		"1 + +": "[[number]]",
+	} {
+		if got := fmt.Sprint(NewParser(SUM, text).Expected()); got != want {
+			t.Errorf("Expected(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestAmbiguities(t *testing.T) {
+	S := NewRule("S", NewProduction("a"))
+	S.add(NewProduction(S, S))
+	ambiguities := NewParser(S, "a a a").Ambiguities()
+	if len(ambiguities) != 1 {
+		t.Fatalf("Ambiguities() = %v", ambiguities)
+	}
+	a := ambiguities[0]
+	if got := fmt.Sprint(a.Alternatives); a.Symbol != "S" || a.Start != 0 || a.End != 3 ||
+		got != "[[S [0-2] S [2-3]] [S [0-1] S [1-3]]]" {
+		t.Errorf("ambiguity = %s [%d-%d] %s", a.Symbol, a.Start, a.End, got)
+	}
+	if ambiguities := NewParser(S, "a a").Ambiguities(); len(ambiguities) != 0 {
+		t.Errorf("Ambiguities() = %v", ambiguities)
+	}
+	if ambiguities := NewParser(S, "b").Ambiguities(); ambiguities != nil {
+		t.Errorf("Ambiguities() = %v", ambiguities)
+	}
+}
+
+func TestPredictLookahead(t *testing.T) {
+	// a sentence of words, one production per word
+	WORD := NewRule("WORD")
+	words := []string{"the", "a", "cat", "dog", "sees", "runs", "quickly", "black"}
+	for _, w := range words {
+		WORD.add(NewProduction(w))
+	}
+	EMPTY := NewRule("EMPTY", NewProduction())
+	WORD.add(NewProduction(EMPTY, TerminalFunc("number", isNumber)))
+	S := NewRule("S", NewProduction(WORD))
+	S.add(NewProduction(S, WORD))
+	g, err := Compile(S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*Parser{NewParser(S, "the black cat sees 1"), g.Parse("the black cat sees 1")} {
+		if p.Err() != nil {
+			t.Fatal(p.Err())
+		}
+		// the words other than the next one are not predicted
+		for _, col := range p.columns {
+			if col.size() > 10 {
+				t.Errorf("column %d has %d states:\n%v", col.index, col.size(), col)
+			}
+		}
+	}
+	// the productions left out are still expected
+	if got := fmt.Sprint(NewParser(S, "the").Expected()); got != "[the a cat dog sees runs quickly black [number]]" {
+		t.Errorf("Expected() = %s", got)
+	}
+}
+
+func TestSharedProduction(t *testing.T) {
+	// A and B share &Epsilon: their states are still told apart
+	A := NewRule("A", &Epsilon)
+	B := NewRule("B", &Epsilon)
+	S := NewRule("S", NewProduction(A, B, "x"))
+	p := NewParser(S, "x")
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	trees := p.Trees()
+	if len(trees) != 1 {
+		t.Fatalf("%d trees, want 1", len(trees))
+	}
+	if got, want := trees[0].Children()[0].SExpr(), "(S (A) (B) x)"; got != want {
+		t.Errorf("tree = %s, want %s", got, want)
+	}
+	if got := Score[uint64](p, semiring.Counting{}, func(*Production) uint64 { return 1 }); got != 1 {
+		t.Errorf("count = %d, want 1", got)
+	}
+}
+
+func TestNewProductionBadTerm(t *testing.T) {
+	defer func() {
+		want := "earley3: NewProduction: a term is a *Terminal, *Matcher, *Rule or string, not int"
+		if r := recover(); r != want {
+			t.Errorf("panic = %v, want %q", r, want)
+		}
+	}()
+	NewProduction("a", 42)
 }
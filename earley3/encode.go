@@ -0,0 +1,311 @@
+package earley3
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+ * Trees are encoded in three forms, each with its decoder:
+ *
+ * S-expressions, a rule node being its name and children in parentheses, a
+ * token being itself, quoted when it has to be:
+ *
+ *   (ɣ (SUM (SUM (NUM 1)) + (NUM 22)))
+ *
+ * JSON, with the symbol of rule nodes, the token of token nodes, and the span
+ * of both:
+ *
+ *   {"symbol": "NUM", "span": [2, 3], "children": [{"token": "22", "span": [2, 3]}]}
+ *
+ * and the bracketed format of the Penn Treebank, indented, with parentheses
+ * escaped as -LRB- and -RRB-.
+ *
+ * Decoded trees have no parse behind them: they only answer Symbol, Token,
+ * Span and Children, which is what Equal compares.
+ *
+ * Only JSON has the spans. The bracketed forms number the tokens of the tree
+ * in order instead, which are the spans of the input for the trees of a text
+ * with every token kept, but not once a shape drops tokens nor for the edges
+ * of a lattice spanning several nodes: Equal ignores the spans of trees
+ * decoded from brackets.
+ */
+
+/*
+ * the value of a decoded rule node
+ */
+type symbolNode struct {
+	name       string
+	start, end int
+}
+
+func (self symbolNode) String() string {
+	return fmt.Sprintf("%s [%d-%d]", self.name, self.start, self.end)
+}
+
+/*
+ * whether the trees have the same symbols, tokens and spans. the spans of a
+ * tree decoded by ParseSExpr or ParsePTB are left out
+ */
+func (self *Node) Equal(other *Node) bool {
+	if self.Symbol() != other.Symbol() || self.Token() != other.Token() ||
+		len(self.children) != len(other.children) {
+		return false
+	}
+	start, end := self.Span()
+	otherStart, otherEnd := other.Span()
+	if !self.bracketed && !other.bracketed && (start != otherStart || end != otherEnd) {
+		return false
+	}
+	for i, child := range self.children {
+		if !child.Equal(other.children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * the tree as an S-expression
+ */
+func (self *Node) SExpr() string {
+	var b strings.Builder
+	self.writeSExpr(&b)
+	return b.String()
+}
+
+func (self *Node) writeSExpr(b *strings.Builder) {
+	if self.Symbol() == "" {
+		b.WriteString(sexprAtom(self.Token()))
+		return
+	}
+	b.WriteString("(" + sexprAtom(self.Symbol()))
+	for _, child := range self.children {
+		b.WriteString(" ")
+		child.writeSExpr(b)
+	}
+	b.WriteString(")")
+}
+
+func sexprAtom(s string) string {
+	if s == "" || strings.ContainsAny(s, "()\"\\") || strings.IndexFunc(s, unicode.IsSpace) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+/*
+ * decode a tree encoded by SExpr
+ */
+func ParseSExpr(text string) (*Node, error) {
+	tokens, err := lexSExpr(text)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBrackets(tokens)
+}
+
+/*
+ * the tree in the bracketed format of the Penn Treebank
+ */
+func (self *Node) PTB() string {
+	var b strings.Builder
+	self.writePTB(&b, 0)
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (self *Node) writePTB(b *strings.Builder, indent int) {
+	if self.Symbol() == "" {
+		b.WriteString(ptbEscape(self.Token()))
+		return
+	}
+	b.WriteString("(" + ptbEscape(self.Symbol()))
+	leaves := true
+	for _, child := range self.children {
+		leaves = leaves && child.Symbol() == ""
+	}
+	for _, child := range self.children {
+		if leaves {
+			b.WriteString(" ")
+		} else {
+			// one line per child, below the label
+			b.WriteString("\n" + strings.Repeat("  ", indent+1))
+		}
+		child.writePTB(b, indent+1)
+	}
+	b.WriteString(")")
+}
+
+var ptbEscapes = strings.NewReplacer("(", "-LRB-", ")", "-RRB-")
+var ptbUnescapes = strings.NewReplacer("-LRB-", "(", "-RRB-", ")")
+
+func ptbEscape(s string) string {
+	return ptbEscapes.Replace(s)
+}
+
+/*
+ * decode a tree in the bracketed format of the Penn Treebank
+ */
+func ParsePTB(text string) (*Node, error) {
+	tokens := []string{}
+	for _, field := range strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(text)) {
+		if field != "(" && field != ")" {
+			field = ptbUnescapes.Replace(field)
+			if field == "(" || field == ")" {
+				// keep escaped brackets apart from the real ones
+				field = strconv.Quote(field)
+			} else {
+				field = sexprAtom(field)
+			}
+		}
+		tokens = append(tokens, field)
+	}
+	return decodeBrackets(tokens)
+}
+
+/*
+ * split an S-expression into "(", ")" and atoms, quoted atoms keeping their
+ * quotes
+ */
+func lexSExpr(text string) ([]string, error) {
+	tokens := []string{}
+	for len(text) > 0 {
+		c := text[0]
+		switch {
+		case c == '(' || c == ')':
+			tokens = append(tokens, text[:1])
+			text = text[1:]
+		case unicode.IsSpace(rune(c)):
+			text = text[1:]
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(text)
+			if err != nil {
+				return nil, fmt.Errorf("earley3: tree: bad string at %q", text)
+			}
+			tokens = append(tokens, quoted)
+			text = text[len(quoted):]
+		default:
+			end := strings.IndexFunc(text, func(r rune) bool {
+				return r == '(' || r == ')' || r == '"' || unicode.IsSpace(r)
+			})
+			if end < 0 {
+				end = len(text)
+			}
+			tokens = append(tokens, text[:end])
+			text = text[end:]
+		}
+	}
+	return tokens, nil
+}
+
+/*
+ * build the tree of the bracket tokens, numbering its tokens in order for
+ * spans: the brackets do not hold the spans of the input
+ */
+func decodeBrackets(tokens []string) (*Node, error) {
+	d := &bracketDecoder{tokens: tokens}
+	n, err := d.node()
+	if err != nil {
+		return nil, err
+	}
+	if d.i != len(tokens) {
+		return nil, fmt.Errorf("earley3: tree: unexpected %s after the tree", tokens[d.i])
+	}
+	return n, nil
+}
+
+type bracketDecoder struct {
+	tokens []string
+	i      int
+	// the tokens of the input so far
+	pos int
+}
+
+func (self *bracketDecoder) atom() (string, error) {
+	if self.i == len(self.tokens) {
+		return "", fmt.Errorf("earley3: tree: unexpected end")
+	}
+	t := self.tokens[self.i]
+	if t == "(" || t == ")" {
+		return "", fmt.Errorf("earley3: tree: unexpected %s", t)
+	}
+	self.i++
+	if strings.HasPrefix(t, `"`) {
+		return strconv.Unquote(t)
+	}
+	return t, nil
+}
+
+func (self *bracketDecoder) node() (*Node, error) {
+	if self.i < len(self.tokens) && self.tokens[self.i] != "(" {
+		token, err := self.atom()
+		if err != nil {
+			return nil, err
+		}
+		self.pos++
+		return &Node{value: tokenLeaf{token, self.pos - 1, self.pos}, bracketed: true}, nil
+	}
+	self.i++ // (
+	name, err := self.atom()
+	if err != nil {
+		return nil, err
+	}
+	n := &Node{bracketed: true}
+	start := self.pos
+	for self.i < len(self.tokens) && self.tokens[self.i] != ")" {
+		child, err := self.node()
+		if err != nil {
+			return nil, err
+		}
+		n.children = append(n.children, child)
+	}
+	if self.i == len(self.tokens) {
+		return nil, fmt.Errorf("earley3: tree: missing ) after %s", name)
+	}
+	self.i++ // )
+	n.value = symbolNode{name, start, self.pos}
+	return n, nil
+}
+
+type jsonNode struct {
+	Symbol   string  `json:"symbol,omitempty"`
+	Token    string  `json:"token,omitempty"`
+	Span     [2]int  `json:"span"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+/*
+ * encode the tree as JSON
+ */
+func (self *Node) MarshalJSON() ([]byte, error) {
+	jn := jsonNode{Symbol: self.Symbol(), Token: self.Token(), Children: self.children}
+	jn.Span[0], jn.Span[1] = self.Span()
+	return json.Marshal(jn)
+}
+
+/*
+ * decode a tree encoded by MarshalJSON
+ */
+func (self *Node) UnmarshalJSON(data []byte) error {
+	var jn jsonNode
+	if err := json.Unmarshal(data, &jn); err != nil {
+		return err
+	}
+	switch {
+	case jn.Symbol != "" && jn.Token == "":
+		self.value = symbolNode{jn.Symbol, jn.Span[0], jn.Span[1]}
+		self.children = jn.Children
+	case jn.Symbol == "" && jn.Token != "" && len(jn.Children) == 0:
+		if jn.Span[1] <= jn.Span[0] {
+			return fmt.Errorf("earley3: tree: token %q spans %v", jn.Token, jn.Span)
+		}
+		self.value = tokenLeaf{jn.Token, jn.Span[0], jn.Span[1]}
+	default:
+		return fmt.Errorf("earley3: tree: a node is either a symbol or a token")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package earley3
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEncodeTree(t *testing.T) {
+	NUM := NewRule("NUM", NewProduction(TerminalFunc("number", isNumber)))
+	EMPTY := NewRule("EMPTY", NewProduction())
+	SUM := NewRule("SUM", NewProduction(NUM, EMPTY))
+	SUM.add(NewProduction(SUM, "+", "(", NUM, ")"))
+	tree := NewParser(SUM, "1 + ( 22 )").Trees()[0]
+
+	sexpr := `(ɣ (SUM (SUM (NUM 1) (EMPTY)) + "(" (NUM 22) ")"))`
+	if got := tree.SExpr(); got != sexpr {
+		t.Errorf("SExpr() = %s, want %s", got, sexpr)
+	}
+	ptb := `(ɣ
+  (SUM
+    (SUM
+      (NUM 1)
+      (EMPTY))
+    +
+    -LRB-
+    (NUM 22)
+    -RRB-))
+`
+	if got := tree.PTB(); got != ptb {
+		t.Errorf("PTB() =\n%s\nwant\n%s", got, ptb)
+	}
+	data, err := json.Marshal(tree.Children()[0].Children()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"symbol":"SUM","span":[0,1],"children":[` +
+		`{"symbol":"NUM","span":[0,1],"children":[{"token":"1","span":[0,1]}]},` +
+		`{"symbol":"EMPTY","span":[1,1]}]}`; string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+
+	fromSExpr, err := ParseSExpr(sexpr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromPTB, err := ParsePTB(ptb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(tree)
+	fromJSON := &Node{}
+	if err := json.Unmarshal(data, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	for name, decoded := range map[string]*Node{"sexpr": fromSExpr, "ptb": fromPTB, "json": fromJSON} {
+		if !decoded.Equal(tree) {
+			t.Errorf("%s: decoded %s, want %s", name, decoded, tree)
+		}
+	}
+	if other := NewParser(SUM, "1 + ( 2 )").Trees()[0]; other.Equal(tree) {
+		t.Errorf("%s equals %s", other, tree)
+	}
+
+	for _, bad := range []string{"(A", "(A b) c", "()", `(A "b)`} {
+		if _, err := ParseSExpr(bad); err == nil {
+			t.Errorf("ParseSExpr(%q) succeeded", bad)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"symbol":"A","token":"b","span":[0,1]}`), &Node{}); err == nil {
+		t.Errorf("decoded a node with a symbol and a token")
+	}
+}
+
+func TestEncodeShapedTree(t *testing.T) {
+	// the parentheses are dropped, and the tokens of N start at 1
+	N := NewRule("N", NewProduction("a"))
+	S := NewRule("S", NewProduction("(", N, ")")).Annotate(DropTokens)
+	tree := NewParser(S, "( a )").Trees()[0]
+	if got, want := tree.SExpr(), "(\u0263 (S (N a)))"; got != want {
+		t.Fatalf("SExpr() = %s, want %s", got, want)
+	}
+
+	// an edge of the lattice spans two nodes
+	CITY := NewRule("CITY", NewProduction("newark"))
+	WIN := NewRule("WIN", NewProduction(CITY, "wins"))
+	lattice := NewLatticeParser(WIN, &Lattice{Nodes: 4, Edges: []Edge{
+		{From: 0, To: 2, Token: "newark"},
+		{From: 2, To: 3, Token: "wins"},
+	}}).Trees()[0]
+
+	for _, tree := range []*Node{tree, lattice} {
+		fromSExpr, err := ParseSExpr(tree.SExpr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromPTB, err := ParsePTB(tree.PTB())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(tree)
+		fromJSON := &Node{}
+		if err := json.Unmarshal(data, fromJSON); err != nil {
+			t.Fatal(err)
+		}
+		for name, decoded := range map[string]*Node{"sexpr": fromSExpr, "ptb": fromPTB, "json": fromJSON} {
+			if !decoded.Equal(tree) || !tree.Equal(decoded) {
+				t.Errorf("%s: decoded %s, want %s", name, decoded, tree)
+			}
+		}
+	}
+}
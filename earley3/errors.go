@@ -0,0 +1,118 @@
+package earley3
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ * Reports where the input stops being the prefix of a sentence
+ */
+type ParseError struct {
+	// index of the unexpected token, or the number of tokens at the end of
+	// input. the node of a lattice
+	Pos int
+	// the unexpected token, empty at the end of input. the tokens of the
+	// edges leaving Pos joined by | for a lattice
+	Token string
+	EOF   bool
+	// the terminals that could have come at Pos
+	Expected []string
+}
+
+func (self *ParseError) Error() string {
+	found := fmt.Sprintf("%q", self.Token)
+	if self.EOF {
+		found = "end of input"
+	}
+	msg := fmt.Sprintf("unexpected %s at %d", found, self.Pos)
+	if len(self.Expected) > 0 {
+		msg += ", expected " + strings.Join(self.Expected, " or ")
+	}
+	return msg
+}
+
+/*
+ * return nil if the parse succeeded, the *LimitError that stopped it or the
+ * error of an invalid lattice, or a *ParseError locating the problem.
+ * the error is at the last column that is not empty: no state could go on
+ * past it. in a text, that is the column before the first empty one
+ */
+func (self *Parser) Err() error {
+	if self.err != nil {
+		return self.err
+	}
+	if self.finalState != nil {
+		return nil
+	}
+	pos := len(self.columns) - 1
+	for pos > 0 && len(self.columns[pos].states) == 0 {
+		pos--
+	}
+	col := self.columns[pos]
+	tokens := make([]string, len(col.out))
+	for i, e := range col.out {
+		tokens[i] = self.edges[e].Token
+	}
+	return &ParseError{Pos: pos, Token: strings.Join(tokens, "|"), EOF: len(col.out) == 0,
+		Expected: col.expectedTerminals(self.corners)}
+}
+
+/*
+ * the terminals the states of the column wait for, without duplicates,
+ * including the states the predictor left out for not starting with the
+ * lookahead
+ */
+func (self *TableColumn) expectedTerminals(corners *leftCorners) []string {
+	expected := []string{}
+	seen := map[string]bool{}
+	// the states of the column and the ones the predictor left out, by
+	// production and dot-location
+	type dotted struct {
+		production *Production
+		dotIndex   int
+	}
+	closure := map[dotted]bool{}
+	queue := []dotted{}
+	for _, st := range self.states {
+		queue = append(queue, dotted{st.production, st.dotIndex})
+	}
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		if closure[d] || d.dotIndex >= d.production.size() {
+			continue
+		}
+		closure[d] = true
+		switch term := d.production.get(d.dotIndex).(type) {
+		case *Rule:
+			for _, prod := range term.productions {
+				queue = append(queue, dotted{prod, 0})
+			}
+			if corners.nullable[term] {
+				queue = append(queue, dotted{d.production, d.dotIndex + 1})
+			}
+		case *Terminal, *Matcher:
+			s := fmt.Sprint(term)
+			if !seen[s] {
+				seen[s] = true
+				expected = append(expected, s)
+			}
+		}
+	}
+	return expected
+}
+
+/*
+ * the terminals that may come after the input, or at the error of a failed
+ * parse: what to type next. nil when a limit stopped the parse
+ */
+func (self *Parser) Expected() []string {
+	if self.err != nil {
+		return nil
+	}
+	if err, ok := self.Err().(*ParseError); ok {
+		return err.Expected
+	}
+	return self.columns[len(self.columns)-1].expectedTerminals(self.corners)
+}
@@ -0,0 +1,71 @@
+package earley3
+
+import "sort"
+
+/*
+ * a span of the input derived from the start rule: the tokens Start to End
+ */
+type Match struct {
+	Start, End int
+}
+
+/*
+ * the spans of the space-delimited text that are sentences of the grammar,
+ * like the FindAll methods of package regexp: the leftmost of the longest
+ * matches first, then the leftmost longest match after it and so on, leaving
+ * empty matches out. with WithAllMatches, every match instead, ordered by
+ * start and end.
+ * the matches are found in a single pass, the start rule being predicted in
+ * every column and not only in the first: the error is the *LimitError that
+ * stopped the parse, if any
+ */
+func (self *Grammar) FindAll(text string, opts ...Option) ([]Match, error) {
+	cfg := newConfig(opts)
+	p := self.Parse(text, append(opts, withSeeds([]*Rule{self.start}))...)
+	if p.err != nil {
+		return nil, p.err
+	}
+	matches := []Match{}
+	for _, col := range p.columns {
+		seen := map[int]bool{}
+		for _, st := range col.states {
+			if st.isCompleted() && st.rule == self.start && !seen[st.startCol.index] {
+				seen[st.startCol.index] = true
+				matches = append(matches, Match{st.startCol.index, col.index})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End < matches[j].End
+	})
+	if cfg.allMatches {
+		return matches, nil
+	}
+	return leftmostLongest(matches), nil
+}
+
+/*
+ * the non empty matches, ordered by start and end, that do not overlap the
+ * longest match before them
+ */
+func leftmostLongest(matches []Match) []Match {
+	found := []Match{}
+	for i := 0; i < len(matches); {
+		start := matches[i].Start
+		longest := matches[i]
+		for ; i < len(matches) && matches[i].Start == start; i++ {
+			longest = matches[i]
+		}
+		if longest.End == longest.Start {
+			continue
+		}
+		if len(found) > 0 && start < found[len(found)-1].End {
+			continue
+		}
+		found = append(found, longest)
+	}
+	return found
+}
@@ -0,0 +1,28 @@
+package earley3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindAll(t *testing.T) {
+	NUM := NewRule("NUM", NewProduction(TerminalFunc("number", isNumber)))
+	SUM := NewRule("SUM", NewProduction(NUM))
+	SUM.add(NewProduction(SUM, "+", NUM))
+	g, err := Compile(SUM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "add 1 + 2 to 3 , then + 4 + 5"
+	matches, err := g.FindAll(text)
+	if want := []Match{{1, 4}, {5, 6}, {9, 12}}; err != nil || !reflect.DeepEqual(matches, want) {
+		t.Errorf("FindAll() = %v, %v, want %v", matches, err, want)
+	}
+	matches, err = g.FindAll("1 + 2 x", WithAllMatches())
+	if want := []Match{{0, 1}, {0, 3}, {2, 3}}; err != nil || !reflect.DeepEqual(matches, want) {
+		t.Errorf("FindAll(WithAllMatches) = %v, %v, want %v", matches, err, want)
+	}
+	if _, err := g.FindAll(text, WithMaxChartSize(5)); err == nil {
+		t.Errorf("FindAll() with a limit: no error")
+	}
+}
@@ -0,0 +1,320 @@
+package earley3
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+/*
+ * tunes Generate
+ */
+type GenerateOption func(*generator)
+
+/*
+ * how deep Generate nests rules unless told otherwise
+ */
+const DefaultMaxDepth = 12
+
+type generator struct {
+	rules     []*Rule
+	rnd       *rand.Rand
+	maxDepth  int
+	weight    func(*Production) float64
+	coverage  bool
+	mutations float64
+	samples   []string
+
+	// the least depth each rule derives a sentence in. rules deriving no
+	// sentence are missing
+	minDepth map[*Rule]int
+	// the productions the sentences so far were derived with
+	used map[*Production]bool
+}
+
+/*
+ * draw the random choices of Generate from rnd
+ */
+func WithRand(rnd *rand.Rand) GenerateOption {
+	return func(gen *generator) {
+		gen.rnd = rnd
+	}
+}
+
+/*
+ * nest at most n rules in a sentence
+ */
+func WithMaxDepth(n int) GenerateOption {
+	return func(gen *generator) {
+		gen.maxDepth = n
+	}
+}
+
+/*
+ * pick the productions of a rule in proportion to weight. productions weighing
+ * 0 are only picked when nothing else fits the depth
+ */
+func WithWeights(weight func(*Production) float64) GenerateOption {
+	return func(gen *generator) {
+		gen.weight = weight
+	}
+}
+
+/*
+ * prefer the productions no sentence used yet, and generate more sentences
+ * than asked for until every production reachable within the maximal depth
+ * has been used
+ */
+func WithCoverage() GenerateOption {
+	return func(gen *generator) {
+		gen.coverage = true
+	}
+}
+
+/*
+ * turn a share rate of the sentences into near misses: one token of the
+ * grammar is inserted, removed or replaced. a near miss may still happen to
+ * be a sentence
+ */
+func WithMutations(rate float64) GenerateOption {
+	return func(gen *generator) {
+		gen.mutations = rate
+	}
+}
+
+/*
+ * the tokens tried for the matchers of the grammar
+ */
+func WithSamples(tokens ...string) GenerateOption {
+	return func(gen *generator) {
+		gen.samples = tokens
+	}
+}
+
+/*
+ * return n random sentences of the grammar made of the rules reachable from
+ * start, their tokens separated by spaces
+ */
+func Generate(start *Rule, n int, opts ...GenerateOption) ([]string, error) {
+	rules, err := reachableRules(start)
+	if err != nil {
+		return nil, err
+	}
+	gen := &generator{
+		rules:    rules,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		maxDepth: DefaultMaxDepth,
+		used:     map[*Production]bool{},
+	}
+	for _, opt := range opts {
+		opt(gen)
+	}
+	gen.minDepth = minDepths(rules)
+	if d, ok := gen.minDepth[start]; !ok || d > gen.maxDepth {
+		return nil, fmt.Errorf("earley3: generate: %s derives no sentence within depth %d",
+			start.name, gen.maxDepth)
+	}
+
+	sentences := []string{}
+	reachable := gen.reachableProductions(start)
+	// sentences in a row that used no new production
+	stale := 0
+	for len(sentences) < n ||
+		(gen.coverage && len(gen.used) < reachable && stale < len(rules)) {
+		covered := len(gen.used)
+		sentence, err := gen.derive(start, gen.maxDepth, nil)
+		if err != nil {
+			return nil, err
+		}
+		if gen.mutations > 0 && gen.rnd.Float64() < gen.mutations {
+			sentence = gen.mutate(sentence)
+		}
+		sentences = append(sentences, strings.Join(sentence, " "))
+		if len(gen.used) == covered {
+			stale++
+		} else {
+			stale = 0
+		}
+	}
+	return sentences, nil
+}
+
+/*
+ * the least depth each rule derives a sentence in
+ */
+func minDepths(rules []*Rule) map[*Rule]int {
+	minDepth := map[*Rule]int{}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range rules {
+			for _, prod := range r.productions {
+				d, ok := productionDepth(prod, minDepth)
+				if old, seen := minDepth[r]; ok && (!seen || d < old) {
+					minDepth[r] = d
+					changed = true
+				}
+			}
+		}
+	}
+	return minDepth
+}
+
+/*
+ * the least depth prod derives a sentence in, if it does
+ */
+func productionDepth(prod *Production, minDepth map[*Rule]int) (int, bool) {
+	depth := 1
+	for _, r := range prod.rules {
+		d, ok := minDepth[r]
+		if !ok {
+			return 0, false
+		}
+		if d+1 > depth {
+			depth = d + 1
+		}
+	}
+	return depth, true
+}
+
+/*
+ * how many productions a sentence within the maximal depth may use
+ */
+func (self *generator) reachableProductions(start *Rule) int {
+	// the most depth left when reaching each rule
+	left := map[*Rule]int{start: self.maxDepth}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range self.rules {
+			depth, seen := left[r]
+			if !seen {
+				continue
+			}
+			for _, prod := range r.productions {
+				if d, ok := productionDepth(prod, self.minDepth); !ok || d > depth {
+					continue
+				}
+				for _, sub := range prod.rules {
+					if d, seen := left[sub]; !seen || d < depth-1 {
+						left[sub] = depth - 1
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	reachable := 0
+	for r, depth := range left {
+		for _, prod := range r.productions {
+			if d, ok := productionDepth(prod, self.minDepth); ok && d <= depth {
+				reachable++
+			}
+		}
+	}
+	return reachable
+}
+
+/*
+ * append a random sentence of term, nesting at most depth rules, to sentence
+ */
+func (self *generator) derive(term interface{}, depth int,
+	sentence []string) ([]string, error) {
+	switch term := term.(type) {
+	case *Terminal:
+		return append(sentence, term.value), nil
+	case *Matcher:
+		candidates := []string{}
+		for _, token := range self.samples {
+			if term.match(token) {
+				candidates = append(candidates, token)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("earley3: generate: no sample matches %s", term)
+		}
+		return append(sentence, candidates[self.rnd.Intn(len(candidates))]), nil
+	case *Rule:
+		prod := self.choose(term, depth)
+		self.used[prod] = true
+		var err error
+		for _, t := range prod.terms {
+			if sentence, err = self.derive(t, depth-1, sentence); err != nil {
+				return nil, err
+			}
+		}
+		return sentence, nil
+	}
+	return nil, fmt.Errorf("earley3: generate: unknown term %v", term)
+}
+
+/*
+ * pick a production of r deriving a sentence within depth
+ */
+func (self *generator) choose(r *Rule, depth int) *Production {
+	candidates := []*Production{}
+	for _, prod := range r.productions {
+		if d, ok := productionDepth(prod, self.minDepth); ok && d <= depth {
+			candidates = append(candidates, prod)
+		}
+	}
+	if self.coverage {
+		unused := []*Production{}
+		for _, prod := range candidates {
+			if !self.used[prod] {
+				unused = append(unused, prod)
+			}
+		}
+		if len(unused) > 0 {
+			candidates = unused
+		}
+	}
+	if self.weight != nil {
+		total := 0.0
+		for _, prod := range candidates {
+			total += self.weight(prod)
+		}
+		if total > 0 {
+			x := self.rnd.Float64() * total
+			for _, prod := range candidates {
+				if x -= self.weight(prod); x < 0 {
+					return prod
+				}
+			}
+		}
+	}
+	return candidates[self.rnd.Intn(len(candidates))]
+}
+
+/*
+ * insert, remove or replace one token of sentence
+ */
+func (self *generator) mutate(sentence []string) []string {
+	tokens := []string{}
+	for _, r := range self.rules {
+		for _, prod := range r.productions {
+			for _, term := range prod.terms {
+				if t, ok := term.(*Terminal); ok {
+					tokens = append(tokens, t.value)
+				}
+			}
+		}
+	}
+	tokens = append(tokens, self.samples...)
+	if len(tokens) == 0 {
+		return sentence
+	}
+	c := tokens[self.rnd.Intn(len(tokens))]
+	i := self.rnd.Intn(len(sentence) + 1)
+	mutated := append([]string{}, sentence[:i]...)
+	switch op := self.rnd.Intn(3); {
+	case op == 0 || i == len(sentence):
+		mutated = append(mutated, c)
+		mutated = append(mutated, sentence[i:]...)
+	case op == 1:
+		mutated = append(mutated, sentence[i+1:]...)
+	default:
+		mutated = append(mutated, c)
+		mutated = append(mutated, sentence[i+1:]...)
+	}
+	return mutated
+}
@@ -0,0 +1,82 @@
+package earley3
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	E := NewRule("E")
+	N := NewRule("N",
+		NewProduction(TerminalFunc("number", isNumber)),
+		NewProduction("x"))
+	E.add(NewProduction(E, "+", E), NewProduction("(", E, ")"), NewProduction(N))
+
+	sentences, err := Generate(E, 50, WithRand(rand.New(rand.NewSource(1))),
+		WithMaxDepth(6), WithSamples("1", "22", "y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sentences) != 50 {
+		t.Errorf("%d sentences, want 50", len(sentences))
+	}
+	for _, s := range sentences {
+		if err := NewParser(E, s).Err(); err != nil {
+			t.Errorf("Err(%q) = %v", s, err)
+		}
+	}
+
+	// every production is used once, starting with the first sentence
+	sentences, err = Generate(E, 1, WithRand(rand.New(rand.NewSource(1))),
+		WithCoverage(), WithSamples("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := " " + strings.Join(sentences, " ") + " "
+	for _, token := range []string{"+", "(", ")", "x", "1"} {
+		if !strings.Contains(all, " "+token+" ") {
+			t.Errorf("%q: no %q", sentences, token)
+		}
+	}
+
+	// productions weighing 0 are only used to stop the recursion
+	onlyX := func(prod *Production) float64 {
+		if prod.String() == "x" || prod.String() == "N" {
+			return 1
+		}
+		return 0
+	}
+	sentences, err = Generate(E, 10, WithRand(rand.New(rand.NewSource(1))),
+		WithWeights(onlyX))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range sentences {
+		if s != "x" {
+			t.Errorf("weighted sentence %q, want x", s)
+		}
+	}
+
+	sentences, err = Generate(E, 20, WithRand(rand.New(rand.NewSource(1))),
+		WithMutations(1), WithSamples("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected := 0
+	for _, s := range sentences {
+		if NewParser(E, s).Err() != nil {
+			rejected++
+		}
+	}
+	if rejected == 0 {
+		t.Errorf("no mutation of %q is rejected", sentences)
+	}
+
+	if _, err := Generate(E, 1, WithMaxDepth(1)); err == nil {
+		t.Errorf("Generate at depth 1 succeeded")
+	}
+	if _, err := Generate(E, 1, WithCoverage()); err == nil {
+		t.Errorf("Generate without samples succeeded")
+	}
+}
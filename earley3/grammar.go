@@ -0,0 +1,66 @@
+package earley3
+
+/*
+ * A compiled grammar: a snapshot of the rules reachable from a start rule.
+ *
+ * Rules may get productions added after construction (they have to, to refer
+ * to themselves); a Grammar does not see those changes. It holds no mutable
+ * state and may be shared by any number of goroutines parsing in parallel.
+ *
+ *   g, err := Compile(EXPR)
+ *   ...
+ *   p := g.Parse("a + a")
+ */
+type Grammar struct {
+	start   *Rule
+	rules   []*Rule
+	corners *leftCorners
+}
+
+/*
+ * compile the grammar made of the rules reachable from start. rules are
+ * identified by name, so two different rules may not share one
+ */
+func Compile(start *Rule) (*Grammar, error) {
+	rules, err := reachableRules(start)
+	if err != nil {
+		return nil, err
+	}
+	// copy the rules, then point the copied productions at the copies
+	copies := map[*Rule]*Rule{}
+	for _, r := range rules {
+		copies[r] = &Rule{name: r.name, shape: r.shape, alias: r.alias}
+	}
+	g := &Grammar{start: copies[start]}
+	for _, r := range rules {
+		c := copies[r]
+		for _, prod := range r.productions {
+			terms := make([]interface{}, len(prod.terms))
+			for i, term := range prod.terms {
+				if rule, ok := term.(*Rule); ok {
+					terms[i] = copies[rule]
+				} else {
+					terms[i] = term
+				}
+			}
+			c.add(NewProduction(terms...))
+		}
+		g.rules = append(g.rules, c)
+	}
+	g.corners = newLeftCorners(g.start)
+	return g, nil
+}
+
+/*
+ * the start rule of the grammar
+ */
+func (self *Grammar) Start() *Rule {
+	return self.start
+}
+
+/*
+ * parse the space-delimited text
+ */
+func (self *Grammar) Parse(text string, opts ...Option) *Parser {
+	return NewParser(self.start, text, append(opts, withLeftCorners(self.corners))...)
+}
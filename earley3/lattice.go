@@ -0,0 +1,115 @@
+package earley3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+/*
+ * A lattice, or word graph, of alternative tokens: the input of speech and
+ * OCR front-ends, which are not sure of the tokens they heard or read, nor of
+ * where they start and end.
+ *
+ * Its nodes are numbered 0 to Nodes-1, from the start of the input to its
+ * end, and each edge is a token spanning the nodes From to To. A sentence is
+ * a path from the first node to the last one. The nodes are the columns of
+ * the parsing table: where column k holds the one token of a text, it holds
+ * the edges ending at node k of a lattice.
+ *
+ *   p := g.ParseLattice(&Lattice{Nodes: 3, Edges: []Edge{
+ *     {From: 0, To: 1, Token: "a"},
+ *     {From: 1, To: 2, Token: "+"},
+ *     {From: 0, To: 2, Token: "a+", Weight: 0.1},
+ *   }})
+ */
+type Lattice struct {
+	Nodes int
+	Edges []Edge
+}
+
+/*
+ * a token of a lattice. Weight is free for the front-end to use, a probability
+ * say, and is only ever read by ScoreLattice
+ */
+type Edge struct {
+	From, To int
+	Token    string
+	Weight   float64
+}
+
+/*
+ * the lattice of the space-delimited text: a single path, a token per edge
+ */
+func textLattice(text string) *Lattice {
+	tokens := strings.Fields(text)
+	lattice := &Lattice{Nodes: len(tokens) + 1}
+	for i, token := range tokens {
+		lattice.Edges = append(lattice.Edges, Edge{From: i, To: i + 1, Token: token})
+	}
+	return lattice
+}
+
+/*
+ * the edges have to go forward, from node to node of the lattice: the
+ * columns of the table are filled in order
+ */
+func (self *Lattice) validate() error {
+	if self.Nodes < 1 {
+		return fmt.Errorf("earley3: lattice: %d nodes", self.Nodes)
+	}
+	for i, e := range self.Edges {
+		if e.From < 0 || e.To >= self.Nodes || e.From >= e.To {
+			return fmt.Errorf("earley3: lattice: edge %d %q goes from %d to %d, in %d nodes",
+				i, e.Token, e.From, e.To, self.Nodes)
+		}
+	}
+	return nil
+}
+
+/*
+ * parse the sentences of the lattice. the parser of an invalid lattice has
+ * the error of the lattice, and no table
+ */
+func NewLatticeParser(startRule *Rule, lattice *Lattice, opts ...Option) *Parser {
+	return newLatticeParser(context.Background(), startRule, lattice, newConfig(opts))
+}
+
+/*
+ * parse the sentences of the lattice
+ */
+func (self *Grammar) ParseLattice(lattice *Lattice, opts ...Option) *Parser {
+	return NewLatticeParser(self.start, lattice, append(opts, withLeftCorners(self.corners))...)
+}
+
+func newLatticeParser(ctx context.Context, startRule *Rule, lattice *Lattice, cfg *config) *Parser {
+	cfg.ctx = ctx
+	parser := &Parser{tracer: cfg.tracer, cfg: cfg, corners: cfg.corners}
+	if parser.err = lattice.validate(); parser.err != nil {
+		return parser
+	}
+	if parser.corners == nil {
+		parser.corners = newLeftCorners(startRule)
+	}
+	parser.edges = append([]Edge(nil), lattice.Edges...)
+	// the columns are allocated at once
+	columns := make([]TableColumn, lattice.Nodes)
+	parser.columns = make([]*TableColumn, len(columns))
+	for i := range columns {
+		columns[i].index = i
+		parser.columns[i] = &columns[i]
+	}
+	for i, e := range parser.edges {
+		columns[e.From].out = append(columns[e.From].out, i)
+		columns[e.To].in = append(columns[e.To].in, i)
+	}
+	for i := range columns {
+		tokens := make([]string, len(columns[i].in))
+		for j, e := range columns[i].in {
+			tokens[j] = parser.edges[e].Token
+		}
+		columns[i].token = strings.Join(tokens, "|")
+	}
+	parser.finalState = parser.parse(startRule)
+	return parser
+}
@@ -0,0 +1,80 @@
+package earley3
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/liuzl/gearley/semiring"
+)
+
+func TestLattice(t *testing.T) {
+	CITY := NewRule("CITY", NewProduction("new", "york"), NewProduction("newark"))
+	S := NewRule("S", NewProduction(CITY, "wins"))
+	g, err := Compile(S)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "new york" or "newark", and a "yolk" leading nowhere
+	p := g.ParseLattice(&Lattice{Nodes: 4, Edges: []Edge{
+		{From: 0, To: 1, Token: "new", Weight: 0.6},
+		{From: 1, To: 2, Token: "york", Weight: 1},
+		{From: 1, To: 3, Token: "yolk", Weight: 1},
+		{From: 0, To: 2, Token: "newark", Weight: 0.4},
+		{From: 2, To: 3, Token: "wins", Weight: 1},
+	}})
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, tree := range p.Trees() {
+		got = append(got, tree.Children()[0].SExpr())
+	}
+	sort.Strings(got)
+	want := []string{"(S (CITY new york) wins)", "(S (CITY newark) wins)"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("trees = %q, want %q", got, want)
+	}
+	for _, tree := range p.Trees() {
+		city := tree.Children()[0].Children()[0]
+		if len(city.Children()) == 1 {
+			if start, end := city.Children()[0].Span(); start != 0 || end != 2 {
+				t.Errorf("newark spans %d-%d, want 0-2", start, end)
+			}
+		}
+	}
+
+	one := func(*Production) float64 { return 1 }
+	weight := func(e Edge) float64 { return e.Weight }
+	if best := ScoreLattice[float64](p, semiring.Viterbi{}, one, weight); best != 0.6 {
+		t.Errorf("best = %v, want 0.6", best)
+	}
+	count := Score[uint64](p, semiring.Counting{}, func(*Production) uint64 { return 1 })
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	// a text is a lattice of a single path
+	if p := g.Parse("new york wins"); len(p.Trees()) != 1 {
+		t.Errorf("Parse(new york wins): %d trees, want 1", len(p.Trees()))
+	}
+
+	p = g.ParseLattice(&Lattice{Nodes: 3, Edges: []Edge{
+		{From: 0, To: 1, Token: "new"},
+		{From: 1, To: 2, Token: "wins"},
+		{From: 1, To: 2, Token: "yolk"},
+	}})
+	var pe *ParseError
+	if err := p.Err(); !errors.As(err, &pe) || pe.Pos != 1 || pe.Token != "wins|yolk" || pe.EOF {
+		t.Errorf("Err() = %v, want a *ParseError at 1", err)
+	}
+	if want := []string{"york"}; len(pe.Expected) != 1 || pe.Expected[0] != want[0] {
+		t.Errorf("Expected = %q, want %q", pe.Expected, want)
+	}
+
+	p = g.ParseLattice(&Lattice{Nodes: 2, Edges: []Edge{{From: 1, To: 0, Token: "new"}}})
+	if err := p.Err(); err == nil || p.Trees() != nil {
+		t.Errorf("backward edge: Err() = %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package earley3
+
+import (
+	"context"
+	"fmt"
+)
+
+// limits a parse may hit, see LimitError
+const (
+	LimitContext     = "context"
+	LimitItemsPerSet = "items per set"
+	LimitChartSize   = "chart size"
+	LimitTrees       = "trees"
+)
+
+/*
+ * Reports a parse, or the building of its trees, stopped by one of its limits
+ * or by the cancellation of its context
+ */
+type LimitError struct {
+	// one of the Limit constants
+	Limit string
+	// the value of the limit, 0 for LimitContext
+	Max int
+	// index of the column being filled when the parse stopped
+	Pos int
+	// the number of states in the table when the parse stopped
+	Items int
+	// the error of the context for LimitContext, nil otherwise
+	Err error
+}
+
+func (self *LimitError) Error() string {
+	if self.Limit == LimitContext {
+		return fmt.Sprintf("parse stopped at %d with %d states: %v",
+			self.Pos, self.Items, self.Err)
+	}
+	return fmt.Sprintf("parse stopped at %d with %d states: more than %d %s",
+		self.Pos, self.Items, self.Max, self.Limit)
+}
+
+func (self *LimitError) Unwrap() error {
+	return self.Err
+}
+
+/*
+ * parse the space-delimited text, giving up as soon as ctx is done or the
+ * parse outgrows one of the limits set in opts. the parser returned along with
+ * the *LimitError holds the part of the table filled so far
+ */
+func (self *Grammar) ParseContext(ctx context.Context, text string,
+	opts ...Option) (*Parser, error) {
+	p := newParser(ctx, self.start, text, newConfig(opts))
+	return p, p.err
+}
+
+/*
+ * return a *LimitError if the parse has to stop before processing the j-th
+ * state of the i-th column
+ */
+func (self *Parser) check(i, j, doneStates int) error {
+	cfg := self.cfg
+	col := self.columns[i]
+	states := doneStates + col.size() + self.ahead
+	// the error is only built when returned: check runs for every state
+	switch {
+	case j%256 == 0 && cfg.ctx.Err() != nil:
+		return &LimitError{Limit: LimitContext, Pos: i, Items: states, Err: cfg.ctx.Err()}
+	case cfg.maxItemsPerSet > 0 && col.size() > cfg.maxItemsPerSet:
+		return &LimitError{Limit: LimitItemsPerSet, Max: cfg.maxItemsPerSet, Pos: i, Items: states}
+	case cfg.maxChartSize > 0 && states > cfg.maxChartSize:
+		return &LimitError{Limit: LimitChartSize, Max: cfg.maxChartSize, Pos: i, Items: states}
+	}
+	return nil
+}
+
+/*
+ * return the parse trees, giving up as soon as ctx is done. when the parse
+ * has more trees than the limit set by WithMaxTrees, return that many along
+ * with a *LimitError
+ */
+func (self *Parser) TreesContext(ctx context.Context) ([]*Node, error) {
+	if self.finalState == nil {
+		return nil, self.Err()
+	}
+	self.trees = &treeLimits{ctx: ctx, max: self.cfg.maxTrees,
+		pos: len(self.columns) - 1}
+	defer func() { self.trees = nil }()
+	trees := *self.buildTrees(self.finalState)
+	for i, tree := range trees {
+		trees[i] = shapeTree(tree)
+	}
+	return trees, self.trees.err()
+}
+
+/*
+ * the limits of the trees being built: every list of alternatives, of
+ * sub-trees as well as of trees, is cut at max
+ */
+type treeLimits struct {
+	ctx   context.Context
+	max   int
+	pos   int
+	calls int
+	// the cancellation of ctx, which stops everything
+	canceled error
+	// the first list cut at max
+	cut error
+}
+
+func (self *treeLimits) err() error {
+	if self.canceled != nil {
+		return self.canceled
+	}
+	return self.cut
+}
+
+/*
+ * whether to stop building trees
+ */
+func (self *treeLimits) stop() bool {
+	if self == nil {
+		return false
+	}
+	if self.canceled == nil && self.calls%256 == 0 && self.ctx.Err() != nil {
+		self.canceled = &LimitError{Limit: LimitContext, Pos: self.pos, Err: self.ctx.Err()}
+	}
+	self.calls++
+	return self.canceled != nil
+}
+
+/*
+ * whether one more alternative fits in a list of n
+ */
+func (self *treeLimits) allow(n int) bool {
+	if self == nil || self.max <= 0 || n < self.max {
+		return true
+	}
+	if self.cut == nil {
+		self.cut = &LimitError{Limit: LimitTrees, Max: self.max, Pos: self.pos}
+	}
+	return false
+}
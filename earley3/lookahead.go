@@ -0,0 +1,224 @@
+package earley3
+
+/*
+ * The predictor only adds the productions that may start with the token of
+ * the next column, their lookahead, or derive the empty sequence: the others
+ * could never scan it, nor complete in the column, and would only swell the
+ * table.
+ *
+ * The productions a rule may start with are known from its left corners: R
+ * is a left corner of Q when a production of Q is α R β with α deriving the
+ * empty sequence, and the left corner relation, closed by transitivity,
+ * tells the rules a rule may start with and, through their productions, the
+ * terminals.
+ */
+
+/*
+ * a set of small integers
+ */
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (self bitset) add(i int) {
+	self[i/64] |= 1 << (i % 64)
+}
+
+func (self bitset) has(i int) bool {
+	return self[i/64]&(1<<(i%64)) != 0
+}
+
+/*
+ * add the elements of other, and return whether the set changed
+ */
+func (self bitset) union(other bitset) bool {
+	changed := false
+	for i := range self {
+		if self[i]|other[i] != self[i] {
+			self[i] |= other[i]
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (self bitset) intersects(other bitset) bool {
+	for i := range self {
+		if self[i]&other[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * the left corner tables of the rules reachable from a start rule
+ */
+type leftCorners struct {
+	// the terminals, numbered: terminals by value, matchers one by one
+	values   map[string]int
+	matchers map[*Matcher]int
+	// the terminals each production may start with
+	first map[*Production]bitset
+	// the rules and productions deriving the empty sequence
+	nullable     map[*Rule]bool
+	nullableProd map[*Production]bool
+}
+
+func newLeftCorners(start *Rule) *leftCorners {
+	self := &leftCorners{
+		values:       map[string]int{},
+		matchers:     map[*Matcher]int{},
+		first:        map[*Production]bitset{},
+		nullable:     map[*Rule]bool{},
+		nullableProd: map[*Production]bool{},
+	}
+	// the rules, by pointer: rules sharing a name are still told apart
+	rules := []*Rule{start}
+	ids := map[*Rule]int{start: 0}
+	for i := 0; i < len(rules); i++ {
+		for _, prod := range rules[i].productions {
+			for _, term := range prod.terms {
+				switch term := term.(type) {
+				case *Rule:
+					if _, ok := ids[term]; !ok {
+						ids[term] = len(rules)
+						rules = append(rules, term)
+					}
+				case *Terminal:
+					if _, ok := self.values[term.value]; !ok {
+						self.values[term.value] = len(self.values) + len(self.matchers)
+					}
+				case *Matcher:
+					if _, ok := self.matchers[term]; !ok {
+						self.matchers[term] = len(self.values) + len(self.matchers)
+					}
+				}
+			}
+		}
+	}
+	terminals := len(self.values) + len(self.matchers)
+
+	for changed := true; changed; {
+		changed = false
+		for _, r := range rules {
+			for _, prod := range r.productions {
+				// a production shared by rules, such as &Epsilon, makes each
+				// of them nullable
+				if self.nullable[r] && self.nullableProd[prod] || !self.allNullable(prod.terms) {
+					continue
+				}
+				self.nullableProd[prod] = true
+				self.nullable[r] = true
+				changed = true
+			}
+		}
+	}
+
+	// corners[R] are the left corners of R, R included, and first[R] the
+	// terminals starting its productions directly
+	corners := make([]bitset, len(rules))
+	first := make([]bitset, len(rules))
+	for i := range rules {
+		corners[i] = newBitset(len(rules))
+		corners[i].add(i)
+		first[i] = newBitset(terminals)
+	}
+	for i, r := range rules {
+		for _, prod := range r.productions {
+			for _, term := range prod.terms {
+				if sub, ok := term.(*Rule); ok {
+					corners[i].add(ids[sub])
+					if self.nullable[sub] {
+						continue
+					}
+				} else {
+					first[i].add(self.terminalID(term))
+				}
+				break
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for a := range corners {
+			for b := range corners {
+				if b != a && corners[a].has(b) && corners[a].union(corners[b]) {
+					changed = true
+				}
+			}
+		}
+	}
+	for a := range first {
+		for b := range first {
+			if b != a && corners[a].has(b) {
+				first[a].union(first[b])
+			}
+		}
+	}
+
+	for _, r := range rules {
+		for _, prod := range r.productions {
+			set := newBitset(terminals)
+			for _, term := range prod.terms {
+				if sub, ok := term.(*Rule); ok {
+					set.union(first[ids[sub]])
+					if self.nullable[sub] {
+						continue
+					}
+				} else {
+					set.add(self.terminalID(term))
+				}
+				break
+			}
+			self.first[prod] = set
+		}
+	}
+	return self
+}
+
+func (self *leftCorners) terminalID(term interface{}) int {
+	if m, ok := term.(*Matcher); ok {
+		return self.matchers[m]
+	}
+	return self.values[term.(*Terminal).value]
+}
+
+func (self *leftCorners) allNullable(terms []interface{}) bool {
+	for _, term := range terms {
+		if r, ok := term.(*Rule); !ok || !self.nullable[r] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * the terminals matching token, none at the end of the input
+ */
+func (self *leftCorners) lookahead(token string, end bool) bitset {
+	la := newBitset(len(self.values) + len(self.matchers))
+	if end {
+		return la
+	}
+	if id, ok := self.values[token]; ok {
+		la.add(id)
+	}
+	for m, id := range self.matchers {
+		if m.match(token) {
+			la.add(id)
+		}
+	}
+	return la
+}
+
+/*
+ * whether the predictor adds prod given the lookahead la. the productions the
+ * tables do not know, such as the gamma rule's, are always added
+ */
+func (self *leftCorners) predicts(prod *Production, la bitset) bool {
+	first, ok := self.first[prod]
+	return !ok || self.nullableProd[prod] || first.intersects(la)
+}
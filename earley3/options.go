@@ -0,0 +1,100 @@
+package earley3
+
+import (
+	"context"
+
+	"github.com/liuzl/gearley/trace"
+)
+
+/*
+ * tunes a parse
+ */
+type Option func(*config)
+
+type config struct {
+	ctx            context.Context
+	tracer         trace.Tracer
+	maxItemsPerSet int
+	maxChartSize   int
+	maxTrees       int
+	// the left corner tables of a compiled grammar, computed for the
+	// parse otherwise
+	corners *leftCorners
+	// the rules predicted in every column, for constituents to start
+	// anywhere
+	seeds []*Rule
+	// report every match of FindAll
+	allMatches bool
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{ctx: context.Background(), tracer: trace.Nop{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+/*
+ * report every step of the parse, and of building its trees, to t
+ */
+func WithTracer(t trace.Tracer) Option {
+	return func(cfg *config) {
+		cfg.tracer = t
+	}
+}
+
+/*
+ * stop the parse with a *LimitError when a column grows beyond n states
+ */
+func WithMaxItemsPerSet(n int) Option {
+	return func(cfg *config) {
+		cfg.maxItemsPerSet = n
+	}
+}
+
+/*
+ * stop the parse with a *LimitError when the table holds more than n states
+ */
+func WithMaxChartSize(n int) Option {
+	return func(cfg *config) {
+		cfg.maxChartSize = n
+	}
+}
+
+/*
+ * build at most n trees, and at most n alternatives for any sub-tree
+ */
+func WithMaxTrees(n int) Option {
+	return func(cfg *config) {
+		cfg.maxTrees = n
+	}
+}
+
+/*
+ * make FindAll report every span matching the start rule, the empty ones and
+ * the ones overlapping others included
+ */
+func WithAllMatches() Option {
+	return func(cfg *config) {
+		cfg.allMatches = true
+	}
+}
+
+/*
+ * predict the rules in every column
+ */
+func withSeeds(rules []*Rule) Option {
+	return func(cfg *config) {
+		cfg.seeds = rules
+	}
+}
+
+/*
+ * parse with the left corner tables of a compiled grammar
+ */
+func withLeftCorners(corners *leftCorners) Option {
+	return func(cfg *config) {
+		cfg.corners = corners
+	}
+}
@@ -0,0 +1,133 @@
+package earley3
+
+import "sort"
+
+/*
+ * a rule deriving the tokens Start to End of the input: the columns, or the
+ * nodes of a lattice
+ */
+type Constituent struct {
+	Symbol     string
+	Start, End int
+}
+
+/*
+ * the constituents of the table: the rules of its completed states, with
+ * their spans, ordered by start, end and name. a failed parse has the
+ * constituents of the input up to the error
+ */
+func (self *Parser) Constituents() []Constituent {
+	seen := map[Constituent]bool{}
+	constituents := []Constituent{}
+	for _, col := range self.columns {
+		for _, st := range col.states {
+			if !st.isCompleted() || st.name == GAMMA_RULE {
+				continue
+			}
+			c := Constituent{st.name, st.startCol.index, col.index}
+			if !seen[c] {
+				seen[c] = true
+				constituents = append(constituents, c)
+			}
+		}
+	}
+	sort.Slice(constituents, func(i, j int) bool {
+		a, b := constituents[i], constituents[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		if a.End != b.End {
+			return a.End < b.End
+		}
+		return a.Symbol < b.Symbol
+	})
+	return constituents
+}
+
+/*
+ * the fewest constituents covering as much of the space-delimited text as
+ * possible, in order: the value left in a text that is not a sentence, such
+ * as the phrases recognised in noisy text. the tokens between two chunks are
+ * the ones no constituent covers.
+ * every rule of the grammar may start anywhere in the table of Chunks, not
+ * only where a sentence could have it. a chunk spanning the same tokens as
+ * others is the one of the rule coming first in the grammar: a sentence is a
+ * single chunk of the start rule. the error is the *LimitError that stopped
+ * the parse, if any
+ */
+func (self *Grammar) Chunks(text string, opts ...Option) ([]Constituent, error) {
+	p := self.Parse(text, append(opts, withSeeds(self.rules))...)
+	if p.err != nil {
+		return nil, p.err
+	}
+	rank := map[string]int{}
+	for i, r := range self.rules {
+		rank[r.name] = i
+	}
+	constituents := p.Constituents()
+	sort.Slice(constituents, func(i, j int) bool {
+		a, b := constituents[i], constituents[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		if a.End != b.End {
+			return a.End < b.End
+		}
+		return rank[a.Symbol] < rank[b.Symbol]
+	})
+	return p.cover(constituents), nil
+}
+
+/*
+ * the fewest of constituents, ordered by start, covering as many tokens of the
+ * input as possible: the first of the constituents of a span is preferred
+ */
+func (self *Parser) cover(constituents []Constituent) []Constituent {
+	// best[k] reaches column k leaving gaps tokens out, with chunks
+	// constituents: the last one, or -1 for a token left out, from column prev
+	type path struct {
+		gaps, chunks int
+		last, prev   int
+	}
+	better := func(a, b path) bool {
+		return a.gaps < b.gaps || a.gaps == b.gaps && a.chunks < b.chunks
+	}
+	n := len(self.columns)
+	best := make([]path, n)
+	for k := range best {
+		best[k] = path{gaps: len(self.edges) + 1, last: -1, prev: -1}
+	}
+	best[0].gaps = 0
+	// the edges go forward and the constituents are ordered by start: relax
+	// the columns in order
+	next := 0
+	for k, col := range self.columns {
+		for _, e := range col.out {
+			to := self.edges[e].To
+			skip := path{gaps: best[k].gaps + 1, chunks: best[k].chunks, last: -1, prev: k}
+			if better(skip, best[to]) {
+				best[to] = skip
+			}
+		}
+		for ; next < len(constituents) && constituents[next].Start == k; next++ {
+			c := constituents[next]
+			if c.End == c.Start {
+				continue
+			}
+			p := path{gaps: best[k].gaps, chunks: best[k].chunks + 1, last: next, prev: k}
+			if better(p, best[c.End]) {
+				best[c.End] = p
+			}
+		}
+	}
+	chunks := []Constituent{}
+	for k := n - 1; k > 0 && best[k].prev >= 0; k = best[k].prev {
+		if best[k].last >= 0 {
+			chunks = append(chunks, constituents[best[k].last])
+		}
+	}
+	for i, j := 0, len(chunks)-1; i < j; i, j = i+1, j-1 {
+		chunks[i], chunks[j] = chunks[j], chunks[i]
+	}
+	return chunks
+}
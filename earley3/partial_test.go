@@ -0,0 +1,41 @@
+package earley3
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestChunks(t *testing.T) {
+	NUM := NewRule("NUM", NewProduction(TerminalFunc("number", isNumber)))
+	SUM := NewRule("SUM", NewProduction(NUM, "+", NUM))
+	g, err := Compile(SUM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := func(cs []Constituent) string {
+		parts := []string{}
+		for _, c := range cs {
+			parts = append(parts, fmt.Sprintf("%s %d-%d", c.Symbol, c.Start, c.End))
+		}
+		return strings.Join(parts, ", ")
+	}
+	if got, want := format(g.Parse("1 + 2 +").Constituents()), "NUM 0-1, SUM 0-3, NUM 2-3"; got != want {
+		t.Errorf("Constituents() = %s, want %s", got, want)
+	}
+	for text, want := range map[string]string{
+		"1 + 2":             "SUM 0-3",
+		"7":                 "NUM 0-1",
+		"1 + 2 x 3 + 4":     "SUM 0-3, SUM 4-7",
+		"x 1 + 2 y 3 + + 4": "SUM 1-4, NUM 5-6, NUM 8-9",
+		"x y":               "",
+	} {
+		cs, err := g.Chunks(text)
+		if err != nil || format(cs) != want {
+			t.Errorf("Chunks(%q) = %s, %v, want %s", text, format(cs), err, want)
+		}
+	}
+	if _, err := g.Chunks("1 + 2 x 3 + 4", WithMaxChartSize(5)); err == nil {
+		t.Errorf("Chunks() with a limit: no error")
+	}
+}
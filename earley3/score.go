@@ -0,0 +1,127 @@
+package earley3
+
+import "github.com/liuzl/gearley/semiring"
+
+/*
+ * Fold every parse of the parser's input into a single value of the semiring
+ * sr: with semiring.Boolean this is whether the input was recognised, with
+ * semiring.Counting the number of trees Trees would return, with
+ * semiring.Viterbi the probability of the best tree, and so on.
+ * weight is the value of one application of a production; terminals weigh
+ * sr.One().
+ */
+func Score[T any](p *Parser, sr semiring.Semiring[T], weight func(*Production) T) T {
+	return ScoreLattice(p, sr, weight, func(Edge) T { return sr.One() })
+}
+
+/*
+ * Score the parses of a lattice, the tokens of an edge weighing edge(e): with
+ * semiring.Viterbi and the probabilities of the edges as their weights, this
+ * is the probability of the best path and tree through the lattice
+ */
+func ScoreLattice[T any](p *Parser, sr semiring.Semiring[T], weight func(*Production) T,
+	edge func(Edge) T) T {
+	if p.finalState == nil {
+		return sr.Zero()
+	}
+	sc := &scorer[T]{
+		parser: p,
+		sr:     sr,
+		weight: weight,
+		edge:   edge,
+		memo:   map[scoreKey]T{},
+		active: map[scoreKey]bool{},
+	}
+	// the gamma production is ours, not the grammar's: it does not weigh
+	return sc.inside(p.finalState.name, p.finalState.production, p.finalState.dotIndex,
+		p.finalState.startCol, p.finalState.endCol)
+}
+
+/*
+ * identifies the state [name, production, dotIndex, startCol] of column endCol
+ */
+type scoreKey struct {
+	name       string
+	production *Production
+	dotIndex   int
+	startCol   int
+	endCol     int
+}
+
+type scorer[T any] struct {
+	parser *Parser
+	sr     semiring.Semiring[T]
+	weight func(*Production) T
+	edge   func(Edge) T
+	memo   map[scoreKey]T
+	// states being scored, to cut cyclic derivations
+	active map[scoreKey]bool
+}
+
+/*
+ * the value of a completed state: its production applied to every derivation
+ * of its terms
+ */
+func (self *scorer[T]) complete(st *TableState) T {
+	return self.sr.Times(self.weight(st.production),
+		self.inside(st.name, st.production, st.dotIndex, st.startCol, st.endCol))
+}
+
+/*
+ * the value of the derivations of the terms of prod before dotIndex, spanning
+ * the input from startCol to endCol
+ */
+func (self *scorer[T]) inside(name string, prod *Production, dotIndex int,
+	startCol, endCol *TableColumn) T {
+	key := scoreKey{name, prod, dotIndex, startCol.index, endCol.index}
+	if v, ok := self.memo[key]; ok {
+		return v
+	}
+	if self.active[key] {
+		return self.sr.Zero()
+	}
+	self.active[key] = true
+	defer delete(self.active, key)
+
+	v := self.sr.Zero()
+	if dotIndex == 0 {
+		if startCol == endCol {
+			v = self.sr.One()
+		}
+		self.memo[key] = v
+		return v
+	}
+	switch term := prod.get(dotIndex - 1).(type) {
+	case *Rule:
+		// the last term spans k..endCol for some k; pick every completed state
+		// of the rule in endCol whose start column holds the previous state
+		for _, st := range endCol.states {
+			if !st.isCompleted() || st.name != term.name ||
+				st.startCol.index < startCol.index {
+				continue
+			}
+			if !st.startCol.contains(name, prod, dotIndex-1, startCol) {
+				continue
+			}
+			v = self.sr.Plus(v, self.sr.Times(
+				self.inside(name, prod, dotIndex-1, startCol, st.startCol),
+				self.complete(st)))
+		}
+	case *Terminal, *Matcher:
+		// the last term is the token of an edge ending at endCol
+		for _, e := range endCol.in {
+			edge := &self.parser.edges[e]
+			if edge.From < startCol.index || !matchesToken(term, edge.Token) {
+				continue
+			}
+			prevCol := self.parser.columns[edge.From]
+			if prevCol.contains(name, prod, dotIndex-1, startCol) {
+				v = self.sr.Plus(v, self.sr.Times(
+					self.inside(name, prod, dotIndex-1, startCol, prevCol),
+					self.edge(*edge)))
+			}
+		}
+	}
+	self.memo[key] = v
+	return v
+}
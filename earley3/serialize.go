@@ -0,0 +1,178 @@
+package earley3
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/liuzl/gearley/internal/bnf"
+)
+
+/*
+ * The JSON form of a grammar lists the rules reachable from the start rule,
+ * the start rule first, each with its productions in order:
+ *
+ *   {"start": "EXPR", "rules": [
+ *     {"name": "EXPR", "productions": [
+ *       [{"kind": "rule", "name": "SYM"}],
+ *       [{"kind": "rule", "name": "EXPR"}, {"kind": "token", "value": "+"}, ...]]},
+ *     ...]}
+ *
+ * Matchers are stored by name ({"kind": "func", "name": "number"}) and have to
+ * be handed back to UnmarshalGrammar. The shapes of a rule are listed by name,
+ * as in "shape": ["inline", "drop-tokens"].
+ */
+
+type jsonGrammar struct {
+	Start string     `json:"start"`
+	Rules []jsonRule `json:"rules"`
+}
+
+type jsonRule struct {
+	Name        string       `json:"name"`
+	Productions [][]jsonTerm `json:"productions"`
+	Shape       []string     `json:"shape,omitempty"`
+	Alias       string       `json:"alias,omitempty"`
+}
+
+type jsonTerm struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+const (
+	kindToken = "token"
+	kindRule  = "rule"
+	kindFunc  = "func"
+)
+
+var shapeNames = []struct {
+	shape Shape
+	name  string
+}{
+	{Hide, "hide"},
+	{Inline, "inline"},
+	{FlattenSingle, "flatten-single"},
+	{DropTokens, "drop-tokens"},
+}
+
+/*
+ * encode the grammar made of the rules reachable from start
+ */
+func MarshalGrammar(start *Rule) ([]byte, error) {
+	rules, err := reachableRules(start)
+	if err != nil {
+		return nil, err
+	}
+	jg := jsonGrammar{Start: start.name}
+	for _, r := range rules {
+		jr := jsonRule{Name: r.name, Productions: [][]jsonTerm{}, Alias: r.alias}
+		for _, sn := range shapeNames {
+			if r.shape&sn.shape != 0 {
+				jr.Shape = append(jr.Shape, sn.name)
+			}
+		}
+		for _, prod := range r.productions {
+			terms := []jsonTerm{}
+			for _, term := range prod.terms {
+				switch term := term.(type) {
+				case *Terminal:
+					terms = append(terms, jsonTerm{Kind: kindToken, Value: term.value})
+				case *Matcher:
+					terms = append(terms, jsonTerm{Kind: kindFunc, Name: term.name})
+				case *Rule:
+					terms = append(terms, jsonTerm{Kind: kindRule, Name: term.name})
+				}
+			}
+			jr.Productions = append(jr.Productions, terms)
+		}
+		jg.Rules = append(jg.Rules, jr)
+	}
+	return json.Marshal(jg)
+}
+
+/*
+ * decode a grammar encoded by MarshalGrammar and return its start rule. the
+ * matchers the grammar refers to are looked up by name in matchers
+ */
+func UnmarshalGrammar(data []byte, matchers ...*Matcher) (*Rule, error) {
+	var jg jsonGrammar
+	if err := json.Unmarshal(data, &jg); err != nil {
+		return nil, err
+	}
+	rules := map[string]*Rule{}
+	for _, jr := range jg.Rules {
+		if _, ok := rules[jr.Name]; ok {
+			return nil, fmt.Errorf("earley3: rule %q defined twice", jr.Name)
+		}
+		r := NewRule(jr.Name)
+		r.alias = jr.Alias
+	shapes:
+		for _, name := range jr.Shape {
+			for _, sn := range shapeNames {
+				if sn.name == name {
+					r.shape |= sn.shape
+					continue shapes
+				}
+			}
+			return nil, fmt.Errorf("earley3: rule %q: unknown shape %q", jr.Name, name)
+		}
+		rules[jr.Name] = r
+	}
+	byName := bnf.ByName(matchers, func(m *Matcher) string { return m.name })
+	for _, jr := range jg.Rules {
+		for _, jterms := range jr.Productions {
+			terms := []interface{}{}
+			for _, jt := range jterms {
+				switch jt.Kind {
+				case kindToken:
+					terms = append(terms, &Terminal{jt.Value})
+				case kindFunc:
+					m, ok := byName[jt.Name]
+					if !ok {
+						return nil, fmt.Errorf("earley3: unknown matcher %q", jt.Name)
+					}
+					terms = append(terms, m)
+				case kindRule:
+					r, ok := rules[jt.Name]
+					if !ok {
+						return nil, fmt.Errorf("earley3: undefined rule %q", jt.Name)
+					}
+					terms = append(terms, r)
+				default:
+					return nil, fmt.Errorf("earley3: unknown term kind %q", jt.Kind)
+				}
+			}
+			rules[jr.Name].add(NewProduction(terms...))
+		}
+	}
+	start, ok := rules[jg.Start]
+	if !ok {
+		return nil, fmt.Errorf("earley3: undefined start rule %q", jg.Start)
+	}
+	return start, nil
+}
+
+/*
+ * return the rules reachable from start, in order of first appearance. rules
+ * are identified by name, so two different rules may not share one
+ */
+func reachableRules(start *Rule) ([]*Rule, error) {
+	rules := []*Rule{start}
+	seen := map[string]*Rule{start.name: start}
+	for i := 0; i < len(rules); i++ {
+		for _, prod := range rules[i].productions {
+			for _, r := range prod.rules {
+				if other, ok := seen[r.name]; ok {
+					if other != r {
+						return nil, fmt.Errorf("earley3: two rules named %q", r.name)
+					}
+					continue
+				}
+				seen[r.name] = r
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,110 @@
+package earley3
+
+import (
+	"testing"
+)
+
+func exprGrammar() *Rule {
+	NUM := NewRule("NUM", NewProduction(TerminalFunc("number", isNumber)))
+	EXPR := NewRule("EXPR", NewProduction(NUM))
+	EXPR.add(NewProduction(EXPR, "+", EXPR))
+	EXPR.add(NewProduction("(", EXPR, ")"))
+	OPT := NewRule("OPT", NewProduction(), NewProduction("!"))
+	START := NewRule("START", NewProduction(EXPR, OPT))
+	return START
+}
+
+const exprBNF = `<START> ::= <EXPR> <OPT>
+<EXPR> ::= <NUM>
+         | <EXPR> "+" <EXPR>
+         | "(" <EXPR> ")"
+<OPT> ::= ""
+        | "!"
+<NUM> ::= [number]
+`
+
+func TestGrammarJSON(t *testing.T) {
+	data, err := MarshalGrammar(exprGrammar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, err := UnmarshalGrammar(data, TerminalFunc("number", isNumber))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := FormatBNF(start); got != exprBNF {
+		t.Errorf("round trip =\n%s\nwant\n%s", got, exprBNF)
+	}
+	if err := NewParser(start, "( 1 + 2 ) + 3 !").Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+	if _, err := UnmarshalGrammar(data); err == nil {
+		t.Errorf("decoded without the number matcher")
+	}
+
+	data, err = MarshalGrammar(NewRule("S", NewProduction("s")).Annotate(Inline | DropTokens).Rename("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start, err = UnmarshalGrammar(data); err != nil {
+		t.Fatal(err)
+	}
+	if start.shape != Inline|DropTokens || start.alias != "s" {
+		t.Errorf("round trip shape = %v, alias = %q", start.shape, start.alias)
+	}
+
+	A := NewRule("A", NewProduction("a"))
+	B := NewRule("A", NewProduction("b"))
+	if _, err := MarshalGrammar(NewRule("S", NewProduction(A, B))); err == nil {
+		t.Errorf("encoded two rules with the same name")
+	}
+}
+
+func TestGrammarBNF(t *testing.T) {
+	if got, _ := FormatBNF(exprGrammar()); got != exprBNF {
+		t.Errorf("FormatBNF() =\n%s\nwant\n%s", got, exprBNF)
+	}
+	start, err := ParseBNF(exprBNF, TerminalFunc("number", isNumber))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := FormatBNF(start); got != exprBNF {
+		t.Errorf("round trip =\n%s\nwant\n%s", got, exprBNF)
+	}
+	// X derives nothing: written as <X> ::= "" it would derive the empty
+	// sequence, and S would accept "a"
+	X := NewRule("X")
+	S := NewRule("S", NewProduction("a", X))
+	if NewParser(S, "a").Err() == nil {
+		t.Errorf("parsed a with an empty rule")
+	}
+	if text, err := FormatBNF(S); err == nil {
+		t.Errorf("FormatBNF() = %q for a rule without productions", text)
+	}
+	for _, bad := range []string{"", `"a"`, `<S> ::= <T>`, `<S> ::= [x]`, `<S> ::= "a`} {
+		if _, err := ParseBNF(bad); err == nil {
+			t.Errorf("ParseBNF(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestGrammarBNFEscapes(t *testing.T) {
+	odd := TerminalFunc("]odd\\", isNumber)
+	C := NewRule("two\nlines", NewProduction("\\", "\n"))
+	B := NewRule(`x y"\`, NewProduction(odd, `"`))
+	A := NewRule("a>b", NewProduction(B, ">", C))
+	want := `<a\>b> ::= <x y"\\> ">" <two\nlines>
+<x y"\\> ::= [\]odd\\] "\""
+<two\nlines> ::= "\\" "\n"
+`
+	if got, _ := FormatBNF(A); got != want {
+		t.Errorf("FormatBNF() =\n%s\nwant\n%s", got, want)
+	}
+	start, err := ParseBNF(want, odd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := FormatBNF(start); got != want || start.name != A.name {
+		t.Errorf("round trip =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+package earley3
+
+/*
+ * Shapes turn parse trees into ASTs, rule by rule, after Lark's conventions:
+ *
+ *   EXPR := NewRule("EXPR", ...).Annotate(FlattenSingle)   // Lark's ?expr
+ *   ITEMS := NewRule("ITEMS", ...).Annotate(Inline)        // Lark's _items
+ *   OP := NewRule("OP", ...).Rename("operator")
+ *
+ * The tokens of the input are the leaves of the trees.
+ */
+type Shape int
+
+const (
+	// leave the nodes of the rule, and their subtrees, out of the trees
+	Hide Shape = 1 << iota
+	// replace the nodes of the rule by their children
+	Inline
+	// replace the nodes of the rule having a single child by that child
+	FlattenSingle
+	// leave the tokens out of the children of the nodes of the rule
+	DropTokens
+)
+
+/*
+ * add shape to the shapes of the rule's nodes, and return the rule
+ */
+func (self *Rule) Annotate(shape Shape) *Rule {
+	self.shape |= shape
+	return self
+}
+
+/*
+ * name the nodes of the rule name in trees, and return the rule
+ */
+func (self *Rule) Rename(name string) *Rule {
+	self.alias = name
+	return self
+}
+
+/*
+ * the root of a tree stays, whatever the shape of its rule
+ */
+func shapeTree(root *Node) *Node {
+	return &Node{value: root.value, children: shapeChildren(root)}
+}
+
+func shapeChildren(n *Node) []*Node {
+	var rule *Rule
+	if st, ok := n.value.(*TableState); ok {
+		rule = st.rule
+	}
+	children := []*Node{}
+	for _, child := range n.children {
+		if _, ok := child.value.(tokenLeaf); ok && rule != nil && rule.shape&DropTokens != 0 {
+			continue
+		}
+		children = append(children, shapeNode(child)...)
+	}
+	return children
+}
+
+/*
+ * the nodes n turns into
+ */
+func shapeNode(n *Node) []*Node {
+	st, ok := n.value.(*TableState)
+	if !ok {
+		// a token
+		return []*Node{n}
+	}
+	rule := st.rule
+	if rule != nil && rule.shape&Hide != 0 {
+		return nil
+	}
+	children := shapeChildren(n)
+	if rule == nil {
+		return []*Node{{value: st, children: children}}
+	}
+	if rule.shape&Inline != 0 || (rule.shape&FlattenSingle != 0 && len(children) == 1) {
+		return children
+	}
+	if rule.alias != "" {
+		renamed := *st
+		renamed.name = rule.alias
+		st = &renamed
+	}
+	return []*Node{{value: st, children: children}}
+}
@@ -0,0 +1,38 @@
+package earley3
+
+import (
+	"context"
+	"testing"
+)
+
+func TestShape(t *testing.T) {
+	NUM := NewRule("NUM", NewProduction(TerminalFunc("number", isNumber))).Annotate(Inline)
+	OP := NewRule("OP", NewProduction("+"), NewProduction("-")).Rename("op")
+	END := NewRule("END", NewProduction(";")).Annotate(Hide)
+	EXPR := NewRule("EXPR").Annotate(FlattenSingle)
+	TERM := NewRule("TERM", NewProduction("(", EXPR, ")"), NewProduction(NUM)).
+		Annotate(DropTokens | FlattenSingle)
+	EXPR.add(NewProduction(EXPR, OP, TERM), NewProduction(TERM))
+	STMT := NewRule("STMT", NewProduction(EXPR, END))
+
+	g, err := Compile(STMT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trees, err := g.Parse("1 + ( 2 - 3 ) ;").TreesContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trees) != 1 {
+		t.Fatalf("%d trees, want 1", len(trees))
+	}
+	if got, want := trees[0].SExpr(), "(ɣ (STMT (EXPR 1 (op +) (EXPR 2 (op -) 3))))"; got != want {
+		t.Errorf("tree = %s, want %s", got, want)
+	}
+
+	// without shapes every rule has its node, and every token its leaf
+	trees, _ = NewParser(NewRule("S", NewProduction(OP)), "-").TreesContext(context.Background())
+	if got, want := trees[0].SExpr(), "(ɣ (S (op -)))"; got != want {
+		t.Errorf("tree = %s, want %s", got, want)
+	}
+}
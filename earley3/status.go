@@ -0,0 +1,46 @@
+package earley3
+
+/*
+ * how far an input is from being a sentence, for interactive inputs to tell
+ * whether to wait for more
+ */
+type Status int
+
+const (
+	// no suffix turns the input into a sentence
+	Invalid Status = iota
+	// the beginning of a sentence, awaiting more
+	Incomplete
+	// a sentence, which may still be the beginning of longer ones
+	Complete
+)
+
+func (self Status) String() string {
+	switch self {
+	case Invalid:
+		return "invalid"
+	case Incomplete:
+		return "incomplete"
+	case Complete:
+		return "complete"
+	}
+	return "unknown"
+}
+
+/*
+ * the status of the input, from the last column of the table: Complete when
+ * the gamma rule completed there, Incomplete when it holds other states, and
+ * Invalid when it is empty. a parse stopped by a limit is Invalid: Err tells
+ * why
+ */
+func (self *Parser) Status() Status {
+	switch {
+	case self.err != nil:
+		return Invalid
+	case self.finalState != nil:
+		return Complete
+	case self.columns[len(self.columns)-1].size() > 0:
+		return Incomplete
+	}
+	return Invalid
+}
@@ -0,0 +1,27 @@
+package earley3
+
+import "testing"
+
+func TestStatus(t *testing.T) {
+	NUM := NewRule("NUM", NewProduction(TerminalFunc("number", isNumber)))
+	SUM := NewRule("SUM", NewProduction(NUM))
+	SUM.add(NewProduction(SUM, "+", NUM))
+	g, err := Compile(SUM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for text, want := range map[string]Status{
+		"1 + 2":   Complete,
+		"1 + 2 +": Incomplete,
+		"":        Incomplete,
+		"1 2":     Invalid,
+		"+":       Invalid,
+	} {
+		if got := g.Parse(text).Status(); got != want {
+			t.Errorf("Status(%q) = %v, want %v", text, got, want)
+		}
+	}
+	if got := g.Parse("1 + 2", WithMaxChartSize(3)).Status(); got != Invalid {
+		t.Errorf("Status() with a limit = %v, want invalid", got)
+	}
+}
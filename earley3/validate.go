@@ -0,0 +1,61 @@
+package earley3
+
+import "fmt"
+
+/*
+ * return the problems of the grammar made of the rules reachable from start,
+ * in order: rules without productions, rules deriving no sentence, and
+ * productions given twice. a grammar with problems still parses, but it has
+ * dead parts. two rules sharing a name are the only problem reported, as
+ * nothing else can be told then
+ */
+func Validate(start *Rule) []error {
+	rules, err := reachableRules(start)
+	if err != nil {
+		return []error{err}
+	}
+	problems := []error{}
+	for _, r := range rules {
+		if len(r.productions) == 0 {
+			problems = append(problems, fmt.Errorf("earley3: rule %s has no productions", r.name))
+		}
+	}
+	minDepth := minDepths(rules)
+	for _, r := range rules {
+		if _, ok := minDepth[r]; !ok && len(r.productions) > 0 {
+			problems = append(problems, fmt.Errorf("earley3: rule %s derives no sentence", r.name))
+		}
+	}
+	for _, r := range rules {
+		for i, prod := range r.productions {
+			for _, other := range r.productions[:i] {
+				if sameTerms(prod, other) {
+					problems = append(problems,
+						fmt.Errorf("earley3: rule %s has production %q twice", r.name, prod))
+					break
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func sameTerms(a, b *Production) bool {
+	if len(a.terms) != len(b.terms) {
+		return false
+	}
+	for i, term := range a.terms {
+		switch term := term.(type) {
+		case *Terminal:
+			other, ok := b.terms[i].(*Terminal)
+			if !ok || other.value != term.value {
+				return false
+			}
+		default:
+			if b.terms[i] != term {
+				return false
+			}
+		}
+	}
+	return true
+}
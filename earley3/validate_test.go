@@ -0,0 +1,31 @@
+package earley3
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	NONE := NewRule("NONE")
+	LOOP := NewRule("LOOP")
+	LOOP.add(NewProduction(LOOP, "l"))
+	A := NewRule("A", NewProduction("a"), NewProduction(&Terminal{"a"}))
+	S := NewRule("S", NewProduction(A), NewProduction(NONE), NewProduction(LOOP))
+	want := []string{
+		"earley3: rule NONE has no productions",
+		"earley3: rule LOOP derives no sentence",
+		`earley3: rule A has production "a" twice`,
+	}
+	problems := Validate(S)
+	if len(problems) != len(want) {
+		t.Fatalf("Validate() = %v, want %v", problems, want)
+	}
+	for i, err := range problems {
+		if err.Error() != want[i] {
+			t.Errorf("problem %d = %v, want %v", i, err, want[i])
+		}
+	}
+	if problems := Validate(exprGrammar()); len(problems) != 0 {
+		t.Errorf("Validate() = %v, want none", problems)
+	}
+	if problems := Validate(NewRule("S", NewProduction(NewRule("A"), NewRule("A")))); len(problems) != 1 {
+		t.Errorf("Validate() = %v, want the shared name", problems)
+	}
+}
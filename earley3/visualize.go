@@ -0,0 +1,325 @@
+package earley3
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+)
+
+/*
+ * write the parsing table as an HTML table: one column per table column,
+ * headed by its index and token, with its states below. completed states have
+ * the class "completed"
+ */
+func (self *Parser) WriteChartHTML(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("<table class=\"earley-chart\">\n<tr>")
+	rows := 0
+	for _, col := range self.columns {
+		ew.printf("<th>[%d] %s</th>", col.index, html.EscapeString(col.token))
+		if col.size() > rows {
+			rows = col.size()
+		}
+	}
+	ew.printf("</tr>\n")
+	for row := 0; row < rows; row++ {
+		ew.printf("<tr>")
+		for _, col := range self.columns {
+			if row >= col.size() {
+				ew.printf("<td></td>")
+				continue
+			}
+			st := col.get(row)
+			class := ""
+			if st.isCompleted() {
+				class = ` class="completed"`
+			}
+			ew.printf("<td%s>%s</td>", class, html.EscapeString(st.String()))
+		}
+		ew.printf("</tr>\n")
+	}
+	ew.printf("</table>\n")
+	return ew.err
+}
+
+/*
+ * write the tree in the Graphviz DOT language, its tokens boxed
+ */
+func (self *Node) WriteDOT(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("digraph tree {\n")
+	id := 0
+	var walk func(n *Node) int
+	walk = func(n *Node) int {
+		me := id
+		id++
+		if leaf, ok := n.value.(tokenLeaf); ok {
+			// tokens are boxed, as in the forest
+			ew.printf("  n%d [shape=box, label=%s];\n", me, strconv.Quote(leaf.token))
+			return me
+		}
+		ew.printf("  n%d [label=%s];\n", me, strconv.Quote(nodeLabel(n.value)))
+		for _, child := range n.children {
+			ew.printf("  n%d -> n%d;\n", me, walk(child))
+		}
+		return me
+	}
+	walk(self)
+	ew.printf("}\n")
+	return ew.err
+}
+
+func nodeLabel(value interface{}) string {
+	if st, ok := value.(*TableState); ok {
+		return fmt.Sprintf("%s [%d-%d]", st.name, st.startCol.index, st.endCol.index)
+	}
+	return fmt.Sprint(value)
+}
+
+/*
+ * The shared packed parse forest holds every tree of the parse at once: a
+ * symbol node [name, start, end] stands for all the ways to derive the tokens
+ * start..end from the rule, and each of those ways is a packed node listing
+ * the children it is made of.
+ */
+
+type forestSymbol struct {
+	name       string
+	start, end int
+}
+
+/*
+ * a child of a packed node: a symbol node, or the token of the edge token
+ */
+type forestChild struct {
+	symbol *forestSymbol
+	token  int
+}
+
+/*
+ * write the shared packed parse forest in the Graphviz DOT language. a symbol
+ * node with several packed nodes is ambiguous: it is drawn in red, with one
+ * diamond per alternative
+ */
+func (self *Parser) WriteForestDOT(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("digraph forest {\n")
+	if self.finalState != nil {
+		f := &forest{parser: self, splits: map[splitKey][][]forestChild{}}
+		root := f.children(self.finalState.name, self.finalState.production, self.finalState.dotIndex,
+			self.finalState.startCol, self.finalState.endCol)[0][0].symbol
+		f.writeDOT(ew, *root)
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+type splitKey struct {
+	name       string
+	production *Production
+	dotIndex   int
+	startCol   int
+	endCol     int
+}
+
+type forest struct {
+	parser *Parser
+	splits map[splitKey][][]forestChild
+}
+
+/*
+ * the packed nodes of a symbol node: the children lists of every completed
+ * state of the rule spanning the symbol's tokens
+ */
+func (self *forest) packed(sym forestSymbol) ([][]forestChild, []*Production) {
+	packed := [][]forestChild{}
+	prods := []*Production{}
+	endCol := self.parser.columns[sym.end]
+	for _, st := range endCol.states {
+		if st.isCompleted() && st.name == sym.name && st.startCol.index == sym.start {
+			for _, children := range self.children(st.name, st.production, st.dotIndex,
+				st.startCol, endCol) {
+				packed = append(packed, children)
+				prods = append(prods, st.production)
+			}
+		}
+	}
+	return packed, prods
+}
+
+/*
+ * every way to split the tokens startCol..endCol among the terms of prod
+ * before dotIndex
+ */
+func (self *forest) children(name string, prod *Production, dotIndex int,
+	startCol, endCol *TableColumn) [][]forestChild {
+	key := splitKey{name, prod, dotIndex, startCol.index, endCol.index}
+	if splits, ok := self.splits[key]; ok {
+		return splits
+	}
+	self.splits[key] = nil // cuts cyclic derivations
+	splits := [][]forestChild{}
+	if dotIndex == 0 {
+		if startCol == endCol {
+			splits = append(splits, []forestChild{})
+		}
+		self.splits[key] = splits
+		return splits
+	}
+	switch term := prod.get(dotIndex - 1).(type) {
+	case *Rule:
+		seen := map[int]bool{}
+		for _, st := range endCol.states {
+			k := st.startCol.index
+			if !st.isCompleted() || st.name != term.name || k < startCol.index || seen[k] {
+				continue
+			}
+			seen[k] = true
+			if !st.startCol.contains(name, prod, dotIndex-1, startCol) {
+				continue
+			}
+			sym := &forestSymbol{term.name, k, endCol.index}
+			for _, prefix := range self.children(name, prod, dotIndex-1, startCol, st.startCol) {
+				splits = append(splits, appendChild(prefix, forestChild{symbol: sym}))
+			}
+		}
+	case *Terminal, *Matcher:
+		for _, e := range endCol.in {
+			edge := &self.parser.edges[e]
+			if edge.From < startCol.index || !matchesToken(term, edge.Token) {
+				continue
+			}
+			prevCol := self.parser.columns[edge.From]
+			if prevCol.contains(name, prod, dotIndex-1, startCol) {
+				for _, prefix := range self.children(name, prod, dotIndex-1, startCol, prevCol) {
+					splits = append(splits, appendChild(prefix, forestChild{token: e}))
+				}
+			}
+		}
+	}
+	self.splits[key] = splits
+	return splits
+}
+
+/*
+ * a symbol node of the forest with several derivations
+ */
+type Ambiguity struct {
+	Symbol     string
+	Start, End int
+	// the children of each derivation, as "NAME [start-end]" for rules and
+	// the token for tokens
+	Alternatives [][]string
+}
+
+/*
+ * the ambiguous symbol nodes of the forest, from the root down
+ */
+func (self *Parser) Ambiguities() []Ambiguity {
+	if self.finalState == nil {
+		return nil
+	}
+	f := &forest{parser: self, splits: map[splitKey][][]forestChild{}}
+	root := *f.children(self.finalState.name, self.finalState.production, self.finalState.dotIndex,
+		self.finalState.startCol, self.finalState.endCol)[0][0].symbol
+	ambiguities := []Ambiguity{}
+	done := map[forestSymbol]bool{root: true}
+	queue := []forestSymbol{root}
+	for len(queue) > 0 {
+		sym := queue[0]
+		queue = queue[1:]
+		packed, _ := f.packed(sym)
+		a := Ambiguity{Symbol: sym.name, Start: sym.start, End: sym.end}
+		for _, children := range packed {
+			alternative := []string{}
+			for _, child := range children {
+				if child.symbol == nil {
+					alternative = append(alternative, self.edges[child.token].Token)
+					continue
+				}
+				alternative = append(alternative, fmt.Sprintf("%s [%d-%d]",
+					child.symbol.name, child.symbol.start, child.symbol.end))
+				if !done[*child.symbol] {
+					done[*child.symbol] = true
+					queue = append(queue, *child.symbol)
+				}
+			}
+			a.Alternatives = append(a.Alternatives, alternative)
+		}
+		if len(packed) > 1 {
+			ambiguities = append(ambiguities, a)
+		}
+	}
+	return ambiguities
+}
+
+func appendChild(prefix []forestChild, child forestChild) []forestChild {
+	children := make([]forestChild, len(prefix), len(prefix)+1)
+	copy(children, prefix)
+	return append(children, child)
+}
+
+func (self *forest) writeDOT(ew *errWriter, root forestSymbol) {
+	symbolID := func(sym forestSymbol) string {
+		return strconv.Quote(fmt.Sprintf("%s_%d_%d", sym.name, sym.start, sym.end))
+	}
+	done := map[forestSymbol]bool{root: true}
+	queue := []forestSymbol{root}
+	tokens := map[int]bool{}
+	for len(queue) > 0 {
+		sym := queue[0]
+		queue = queue[1:]
+		packed, prods := self.packed(sym)
+		label := strconv.Quote(fmt.Sprintf("%s [%d-%d]", sym.name, sym.start, sym.end))
+		if len(packed) > 1 {
+			ew.printf("  %s [label=%s, color=red, fontcolor=red, penwidth=2];\n",
+				symbolID(sym), label)
+		} else {
+			ew.printf("  %s [label=%s];\n", symbolID(sym), label)
+		}
+		for i, children := range packed {
+			from := symbolID(sym)
+			if len(packed) > 1 {
+				// only ambiguous symbols get packed nodes drawn
+				from = strconv.Quote(fmt.Sprintf("%s_%d_%d/%d", sym.name, sym.start, sym.end, i))
+				ew.printf("  %s [shape=diamond, color=red, label=%s];\n",
+					from, strconv.Quote(prods[i].String()))
+				ew.printf("  %s -> %s [color=red];\n", symbolID(sym), from)
+			}
+			for _, child := range children {
+				if child.symbol == nil {
+					tokens[child.token] = true
+					ew.printf("  %s -> t%d;\n", from, child.token+1)
+					continue
+				}
+				ew.printf("  %s -> %s;\n", from, symbolID(*child.symbol))
+				if !done[*child.symbol] {
+					done[*child.symbol] = true
+					queue = append(queue, *child.symbol)
+				}
+			}
+		}
+	}
+	// the edges are numbered from 1, as the columns their tokens end at in
+	// a text
+	for i, edge := range self.parser.edges {
+		if tokens[i] {
+			ew.printf("  t%d [shape=box, label=%s];\n", i+1, strconv.Quote(edge.Token))
+		}
+	}
+}
+
+/*
+ * keeps the first error of a series of writes
+ */
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (self *errWriter) printf(format string, args ...interface{}) {
+	if self.err == nil {
+		_, self.err = fmt.Fprintf(self.w, format, args...)
+	}
+}
@@ -7,19 +7,44 @@ import (
 
 const FLAT_DOT = "\u25CF"
 
+// earleyItem is the rule number rule of the grammar, with a dot before its
+// dot-th symbol, started in the set S(index).
+//
+// Items are plain integers so that the state sets can store them in place,
+// and the garbage collector has no pointer to follow in a chart.
 type earleyItem struct {
-	rule  *rule
-	dot   int
-	index int
+	rule  int32
+	dot   int32
+	index int32
 }
 
-func (t *earleyItem) String() string {
-	rightStrings := make([]string, len(t.rule.right))
-	for i, r := range t.rule.right {
-		rightStrings[i] = r.String()
+// dottedRule is a rule with a dot, compiled by the grammar: the parser looks
+// up everything it needs to know about an item here.
+type dottedRule struct {
+	rule *Rule
+	// left is the id of the left side of the rule
+	left int32
+	// next is the symbol after the dot, nil if the dot is at the end
+	next Symbol
+	// nextID is the id of next if it is a non terminal, -1 otherwise
+	nextID int32
+}
+
+// itemView pairs an item with its grammar, for printing.
+type itemView struct {
+	g    *Grammar
+	item earleyItem
+}
+
+func (v itemView) String() string {
+	t := v.item
+	r := v.g.rules[t.rule]
+	rightStrings := make([]string, len(r.right))
+	for i, s := range r.right {
+		rightStrings[i] = s.String()
 	}
 	return fmt.Sprintf("%v -> %v%v%v (%d)",
-		t.rule.left.String(),
+		r.left.String(),
 		strings.Join(rightStrings[0:t.dot], " "),
 		FLAT_DOT,
 		strings.Join(rightStrings[t.dot:], " "),
@@ -27,18 +52,28 @@ func (t *earleyItem) String() string {
 	)
 }
 
-func (t *earleyItem) isCompleted() bool {
-	return t.dot == t.rule.length()
+func (g *Grammar) view(item earleyItem) itemView {
+	return itemView{g: g, item: item}
+}
+
+func (g *Grammar) dotted(item earleyItem) *dottedRule {
+	return &g.dottedRules[g.dottedBase[item.rule]+item.dot]
+}
+
+func (g *Grammar) ruleOf(item earleyItem) *Rule {
+	return g.rules[item.rule]
 }
 
-func (t *earleyItem) getSymbolAt(i int) symbol {
-	return t.rule.right[i]
+func (g *Grammar) isCompleted(item earleyItem) bool {
+	return g.dotted(item).next == nil
 }
 
-func (t *earleyItem) getNext() symbol {
-	return t.rule.right[t.dot]
+// getSymbolAt returns the i-th symbol of the rule of item.
+func (g *Grammar) getSymbolAt(item earleyItem, i int) Symbol {
+	return g.rules[item.rule].right[i]
 }
 
-func (t *earleyItem) isNextMatchingTerminal(nextRune rune) bool {
-	return t.getNext().isMatchingTerminal(nextRune)
+// advance returns item with its dot moved over one symbol.
+func (t earleyItem) advance() earleyItem {
+	return earleyItem{rule: t.rule, dot: t.dot + 1, index: t.index}
 }
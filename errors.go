@@ -0,0 +1,94 @@
+package gearley
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError reports where the input stops being the prefix of a sentence.
+type ParseError struct {
+	// Pos is the offset, in runes, of the unexpected rune or of the end of
+	// input; in tokens for Parse.
+	Pos int
+	// Found is the unexpected rune; it is meaningless at the end of input.
+	Found rune
+	// Token is the unexpected token of Parse, nil for strings.
+	Token interface{}
+	// EOF is set when the input ended before a sentence was complete.
+	EOF bool
+	// Expected lists the terminals that could have come at Pos.
+	Expected []Symbol
+}
+
+func (e *ParseError) Error() string {
+	found := fmt.Sprintf("%q", e.Found)
+	if e.Token != nil {
+		found = fmt.Sprint(e.Token)
+	}
+	if e.EOF {
+		found = "end of input"
+	}
+	expected := make([]string, len(e.Expected))
+	for i, s := range e.Expected {
+		expected[i] = s.String()
+	}
+	msg := fmt.Sprintf("unexpected %v at %d", found, e.Pos)
+	if len(expected) > 0 {
+		msg += ", expected " + strings.Join(expected, " or ")
+	}
+	return msg
+}
+
+// newParseError locates the error in a chart that did not accept its input:
+// the last set before the first empty one is where no item could go on.
+func newParseError(g *Grammar, st *state, in input) *ParseError {
+	pos := 0
+	for pos < in.length() && st.getAt(pos+1).length() > 0 {
+		pos++
+	}
+	e := &ParseError{Pos: pos, EOF: pos == in.length(), Expected: st.getAt(pos).expectedTerminals(g)}
+	if e.EOF {
+		return e
+	}
+	if runes, ok := in.(runeInput); ok {
+		e.Found = runes[pos]
+	} else {
+		e.Token = in.token(pos)
+	}
+	return e
+}
+
+// Limits a parse may hit, see LimitError.
+const (
+	LimitContext     = "context"
+	LimitItemsPerSet = "items per set"
+	LimitChartSize   = "chart size"
+	LimitTrees       = "trees"
+)
+
+// LimitError reports a parse stopped by one of its limits, or by the
+// cancellation of its context.
+type LimitError struct {
+	// Limit is the limit hit, one of the Limit constants.
+	Limit string
+	// Max is the value of the limit; it is 0 for LimitContext.
+	Max int
+	// Pos is the index of the state set being filled when the parse stopped.
+	Pos int
+	// Items is the number of items in the chart when the parse stopped.
+	Items int
+	// Err is the error of the context for LimitContext, nil otherwise.
+	Err error
+}
+
+func (e *LimitError) Error() string {
+	if e.Limit == LimitContext {
+		return fmt.Sprintf("parse stopped at %d with %d items: %v", e.Pos, e.Items, e.Err)
+	}
+	return fmt.Sprintf("parse stopped at %d with %d items: more than %d %s",
+		e.Pos, e.Items, e.Max, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
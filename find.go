@@ -0,0 +1,71 @@
+package gearley
+
+import "sort"
+
+// Match is a span of an input derived from the start symbol: the runes Start
+// to End.
+type Match struct {
+	Start, End int
+}
+
+// FindAll returns the spans of input that are sentences of the grammar, like
+// the FindAll methods of package regexp: the leftmost of the longest matches
+// first, then the leftmost longest match after it and so on, leaving empty
+// matches out. With WithAllMatches, it returns every match instead, ordered
+// by start and end.
+//
+// The matches are found in a single pass, the start symbol being predicted
+// at every rune and not only at the first, so FindAll only fails when a limit
+// of opts stops the parse.
+func (g *Grammar) FindAll(input string, opts ...Option) ([]Match, error) {
+	runes := stringToRunes(input)
+	cfg := newConfig(opts)
+	if id, ok := g.symbolIDs[g.start()]; ok {
+		cfg.seeds = []int32{id}
+	}
+	st, err := g.buildState(runeInput(runes), cfg)
+	if err != nil {
+		return nil, err
+	}
+	matches := []Match{}
+	for end := range *st {
+		seen := map[int32]bool{}
+		for _, item := range st.getAt(end).items {
+			if g.isCompleted(item) && g.ruleOf(item).left == g.start() && !seen[item.index] {
+				seen[item.index] = true
+				matches = append(matches, Match{Start: int(item.index), End: end})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End < matches[j].End
+	})
+	if cfg.allMatches {
+		return matches, nil
+	}
+	return leftmostLongest(matches), nil
+}
+
+// leftmostLongest returns the non empty matches of matches, ordered by start
+// and end, that do not overlap the longest match before them.
+func leftmostLongest(matches []Match) []Match {
+	found := []Match{}
+	for i := 0; i < len(matches); {
+		start := matches[i].Start
+		longest := matches[i]
+		for ; i < len(matches) && matches[i].Start == start; i++ {
+			longest = matches[i]
+		}
+		if longest.End == longest.Start {
+			continue
+		}
+		if len(found) > 0 && start < found[len(found)-1].End {
+			continue
+		}
+		found = append(found, longest)
+	}
+	return found
+}
@@ -0,0 +1,242 @@
+package gearley
+
+import "fmt"
+
+// Forest holds every tree of an input, sharing their common parts: there is
+// one node for each symbol over each span of the input it derives, whatever
+// the number of trees it appears in (a shared packed parse forest).
+type Forest struct {
+	Root *ForestNode
+}
+
+// ForestNode stands for the trees of Symbol deriving the input from Start to
+// End. A leaf, with a nil Symbol, holds the rune of the input at Start.
+type ForestNode struct {
+	Symbol     Symbol
+	Rune       rune
+	Start, End int
+	// Families are the ways the symbol derives its span: a rule of the
+	// symbol applied to a node for each symbol of the rule's right side.
+	// Leaves have none.
+	Families []*Family
+}
+
+// Family is a rule applied to one node for each symbol of its right side.
+type Family struct {
+	Rule     *Rule
+	Children []*ForestNode
+}
+
+// String returns the symbol and span of the node, or the rune of a leaf.
+func (n *ForestNode) String() string {
+	if n.Symbol == nil {
+		return fmt.Sprintf("%q", n.Rune)
+	}
+	return fmt.Sprintf("%s [%d-%d]", n.Symbol, n.Start, n.End)
+}
+
+// Ambiguities returns the nodes of the forest with several families, from
+// the root down.
+func (f *Forest) Ambiguities() []*ForestNode {
+	ambiguities := []*ForestNode{}
+	f.walk(func(n *ForestNode) {
+		if len(n.Families) > 1 {
+			ambiguities = append(ambiguities, n)
+		}
+	})
+	return ambiguities
+}
+
+// walk calls visit on every node of the forest but its leaves, from the root
+// down, each once.
+func (f *Forest) walk(visit func(n *ForestNode)) {
+	done := map[*ForestNode]bool{f.Root: true}
+	queue := []*ForestNode{f.Root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		visit(n)
+		for _, fam := range n.Families {
+			for _, c := range fam.Children {
+				if c.Symbol != nil && !done[c] {
+					done[c] = true
+					queue = append(queue, c)
+				}
+			}
+		}
+	}
+}
+
+// Count returns the number of trees in the forest. The trees going round a
+// cycle of the forest, a node deriving itself, are not counted.
+func (f *Forest) Count() uint64 {
+	counts := map[*ForestNode]uint64{}
+	var count func(n *ForestNode) uint64
+	count = func(n *ForestNode) uint64 {
+		if c, ok := counts[n]; ok {
+			return c
+		}
+		if n.Symbol == nil {
+			return 1
+		}
+		// a node deriving itself counts for nothing until it is done
+		counts[n] = 0
+		total := uint64(0)
+		for _, fam := range n.Families {
+			c := uint64(1)
+			for _, child := range fam.Children {
+				c *= count(child)
+			}
+			total += c
+		}
+		counts[n] = total
+		return total
+	}
+	return count(f.Root)
+}
+
+// Trees returns the trees of the forest, at most max of them when max is
+// positive, leaving out the trees Count does not count.
+func (f *Forest) Trees(max int) []*Tree {
+	memo := map[*ForestNode][]*Tree{}
+	var trees func(n *ForestNode) []*Tree
+	trees = func(n *ForestNode) []*Tree {
+		if ts, ok := memo[n]; ok {
+			return ts
+		}
+		if n.Symbol == nil {
+			return []*Tree{{Rune: n.Rune, Start: n.Start, End: n.End}}
+		}
+		memo[n] = nil
+		ts := []*Tree{}
+		for _, fam := range n.Families {
+			seqs := [][]*Tree{nil}
+			for _, child := range fam.Children {
+				next := [][]*Tree{}
+				for _, seq := range seqs {
+					for _, t := range trees(child) {
+						if max > 0 && len(next) == max {
+							break
+						}
+						next = append(next, append(append([]*Tree(nil), seq...), t))
+					}
+				}
+				seqs = next
+			}
+			for _, seq := range seqs {
+				if max > 0 && len(ts) == max {
+					break
+				}
+				ts = append(ts, &Tree{Rule: fam.Rule, Start: n.Start, End: n.End, Children: seq})
+			}
+		}
+		memo[n] = ts
+		return ts
+	}
+	return trees(f.Root)
+}
+
+// forestKey identifies the node of a symbol over a span.
+type forestKey struct {
+	symbol     NonTerminal
+	start, end int
+}
+
+// forestBuilder builds the forest of the derivations recorded in a chart.
+type forestBuilder struct {
+	g      *Grammar
+	st     *state
+	runes  []rune
+	nodes  map[forestKey]*ForestNode
+	leaves map[int]*ForestNode
+	// inside memoizes the sequences of children of the items
+	inside map[scoreKey][][]*ForestNode
+}
+
+// forest returns the forest of an accepting chart.
+func (g *Grammar) forest(st *state, runes []rune) *Forest {
+	fb := &forestBuilder{
+		g:      g,
+		st:     st,
+		runes:  runes,
+		nodes:  map[forestKey]*ForestNode{},
+		leaves: map[int]*ForestNode{},
+		inside: map[scoreKey][][]*ForestNode{},
+	}
+	return &Forest{Root: fb.node(g.start(), 0, len(runes))}
+}
+
+// node returns the node of n over the input from start to end.
+func (fb *forestBuilder) node(n NonTerminal, start, end int) *ForestNode {
+	key := forestKey{symbol: n, start: start, end: end}
+	if node, ok := fb.nodes[key]; ok {
+		return node
+	}
+	node := &ForestNode{Symbol: n, Start: start, End: end}
+	// in place before the families, for the cycles to find it
+	fb.nodes[key] = node
+	for _, item := range fb.st.getAt(end).items {
+		if int(item.index) != start || !fb.g.isCompleted(item) || fb.g.ruleOf(item).left != n {
+			continue
+		}
+		for _, children := range fb.children(item, end) {
+			node.Families = append(node.Families, &Family{Rule: fb.g.ruleOf(item), Children: children})
+		}
+	}
+	return node
+}
+
+func (fb *forestBuilder) leaf(pos int) *ForestNode {
+	if leaf, ok := fb.leaves[pos]; ok {
+		return leaf
+	}
+	leaf := &ForestNode{Rune: fb.runes[pos], Start: pos, End: pos + 1}
+	fb.leaves[pos] = leaf
+	return leaf
+}
+
+// children returns the sequences of nodes of the symbols of item before the
+// dot, spanning the input from item.index to pos.
+func (fb *forestBuilder) children(item earleyItem, pos int) [][]*ForestNode {
+	key := scoreKey{item: item, pos: pos}
+	if seqs, ok := fb.inside[key]; ok {
+		return seqs
+	}
+	seqs := [][]*ForestNode{}
+	if item.dot == 0 {
+		if int(item.index) == pos {
+			seqs = append(seqs, nil)
+		}
+		fb.inside[key] = seqs
+		return seqs
+	}
+	extend := func(prev [][]*ForestNode, last *ForestNode) {
+		for _, seq := range prev {
+			seqs = append(seqs, append(append([]*ForestNode(nil), seq...), last))
+		}
+	}
+	prev := earleyItem{rule: item.rule, dot: item.dot - 1, index: item.index}
+	switch s := fb.g.getSymbolAt(item, int(item.dot-1)).(type) {
+	case NonTerminal:
+		// every origin k of a completed item of s in S(pos) holding the
+		// previous item, once
+		done := map[int32]bool{}
+		for _, c := range fb.st.getAt(pos).items {
+			if c.index < item.index || done[c.index] || !fb.g.isCompleted(c) || fb.g.ruleOf(c).left != s {
+				continue
+			}
+			done[c.index] = true
+			if !fb.st.getAt(int(c.index)).hasItem(prev) {
+				continue
+			}
+			extend(fb.children(prev, int(c.index)), fb.node(s, int(c.index), pos))
+		}
+	default:
+		if pos > int(item.index) && s.Match(fb.runes[pos-1]) &&
+			fb.st.getAt(pos-1).hasItem(prev) {
+			extend(fb.children(prev, pos-1), fb.leaf(pos-1))
+		}
+	}
+	fb.inside[key] = seqs
+	return seqs
+}
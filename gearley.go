@@ -4,124 +4,234 @@ package gearley
 // http://loup-vaillant.fr/tutorials/earley-parsing/recogniser
 
 import (
-	"fmt"
-	"strings"
+	"context"
+
+	"github.com/liuzl/gearley/trace"
 )
 
-// state is the highest-level state of the parser.
-type state []*stateSet
+// state is the highest-level state of the parser: the sets S(0) to S(n).
+type state []stateSet
+
+func (st *state) getAt(i int) *stateSet {
+	return &(*st)[i]
+}
 
-func (st *state) String() string {
-	ss := make([]string, len(*st))
-	for i, s := range *st {
-		ss[i] = fmt.Sprint(i, " ", s)
+// Parse reports whether input is a sentence of the grammar: it returns nil if
+// it is, and a *ParseError locating the problem otherwise.
+func (g *Grammar) Parse(input string, opts ...Option) error {
+	return g.ParseContext(context.Background(), input, opts...)
+}
+
+// ParseContext is like Parse, but gives up with a *LimitError wrapping the
+// error of ctx as soon as ctx is done. It also returns a *LimitError when
+// the parse outgrows one of the limits set in opts.
+func (g *Grammar) ParseContext(ctx context.Context, input string, opts ...Option) error {
+	cfg := newConfig(opts)
+	cfg.ctx = ctx
+	return g.parse(runeInput(stringToRunes(input)), cfg)
+}
+
+// parse reports whether in is a sentence of the grammar.
+func (g *Grammar) parse(in input, cfg *config) error {
+	st, err := g.buildState(in, cfg)
+	if err != nil {
+		return err
+	}
+	if g.accepts(st) {
+		return nil
 	}
-	return strings.Join(ss, "\n")
+	return newParseError(g, st, in)
 }
 
-func (st *state) getAt(i int) *stateSet {
-	return (*st)[i]
+// Expected returns the terminals that may come after input, or a
+// *ParseError when input is not the beginning of a sentence.
+func (g *Grammar) Expected(input string, opts ...Option) ([]Symbol, error) {
+	runes := runeInput(stringToRunes(input))
+	st, err := g.buildState(runes, newConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+	last := st.getAt(len(runes))
+	if last.length() == 0 {
+		return nil, newParseError(g, st, runes)
+	}
+	return last.expectedTerminals(g), nil
 }
 
-func (g *grammar) Parse(input string) {
-	inputRunes := stringToRunes(input)
-	st := initializeState(g, inputRunes)
+// accepts reports whether the last set of st holds a completed start item.
+func (g *Grammar) accepts(st *state) bool {
+	for _, item := range st.getAt(len(*st) - 1).items {
+		if g.isCompletedStart(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompletedStart reports whether item derives the start symbol from S(0).
+func (g *Grammar) isCompletedStart(item earleyItem) bool {
+	return item.index == 0 && g.isCompleted(item) && g.ruleOf(item).left == g.start()
+}
+
+// buildState fills in the state sets for in and returns the chart.
+// When a limit of cfg stops it, it returns the chart filled so far along with
+// a *LimitError.
+func (g *Grammar) buildState(in input, cfg *config) (*state, error) {
+	st := initializeState(g, in.length())
+	tracer := cfg.tracer
+	// building the views tracers print costs: skip it when nobody listens
+	tracing := tracer != trace.Tracer(trace.Nop{})
+	if tracing {
+		for _, item := range st.getAt(0).items {
+			tracer.OnPredict(g.view(item), 0)
+		}
+	}
+	// items in the sets before the current one
+	doneItems := 0
 	// the current index in the state 'st' that is being processed - S(stateIndex)
 	stateIndex := 0
 	// outter loop
-	for stateIndex <= len(input) {
+	for stateIndex <= in.length() {
 		set := st.getAt(stateIndex)
-		fmt.Println("NOW SET S(", stateIndex, ")", set)
+		pos := int32(stateIndex)
+		la := g.lookahead(in, stateIndex)
+		for _, id := range cfg.seeds {
+			for _, r := range g.rulesByID[id] {
+				nextItem := earleyItem{rule: r, dot: 0, index: pos}
+				if g.predicts(r, la) && set.putItem(g, nextItem) && tracing {
+					tracer.OnPredict(g.view(nextItem), stateIndex)
+				}
+			}
+		}
 		i := 0
 		// inner loop
 		for i < set.length() {
+			if err := cfg.check(st, stateIndex, i, doneItems); err != nil {
+				return st, err
+			}
 			item := set.items[i]
-			fmt.Println(i, item)
+			d := g.dotted(item)
 			i++
 
-			fmt.Println("NOW item", item)
-
-			if item.isCompleted() {
-				fmt.Println("Completetion")
-				originalSet := st.getAt(item.index)
-				itemsToComplete := originalSet.findItemsToComplete(item.rule.left)
-				fmt.Println("to complete: ", itemsToComplete)
-				for _, itemToComplete := range itemsToComplete {
-					nextItem := &earleyItem{
-						rule:  itemToComplete.rule,
-						dot:   itemToComplete.dot + 1,
-						index: itemToComplete.index,
+			if d.next == nil {
+				// Complete - advance the items waiting for the completed symbol
+				originalSet := st.getAt(int(item.index))
+				for _, j := range originalSet.findItemsToComplete(d.left) {
+					nextItem := originalSet.items[j].advance()
+					if set.putItem(g, nextItem) && tracing {
+						tracer.OnComplete(g.view(nextItem), stateIndex)
 					}
-					set.putItem(nextItem)
 				}
 				continue
 			}
-			if item.isNextMatchingTerminal(inputRunes[stateIndex]) {
-				// Scan - the next symbol is Terminal and matches
-				fmt.Println("Scan - terminal")
-				nextItem := &earleyItem{
-					rule:  item.rule,
-					dot:   item.dot + 1,
-					index: item.index,
+			if d.nextID >= 0 {
+				// Predict - the next symbol is Non Terminal
+				// put the rules for the symbol that may start with the
+				// lookahead to the current set
+				for _, r := range g.rulesByID[d.nextID] {
+					if !g.predicts(r, la) {
+						continue
+					}
+					nextItem := earleyItem{rule: r, dot: 0, index: pos}
+					if set.putItem(g, nextItem) && tracing {
+						tracer.OnPredict(g.view(nextItem), stateIndex)
+					}
+				}
+				// a nullable symbol may complete in this very set, after the
+				// items waiting for it have been looked at: step over it now
+				// (Aycock and Horspool)
+				if g.nullableIDs[d.nextID] {
+					nextItem := item.advance()
+					if set.putItem(g, nextItem) && tracing {
+						tracer.OnComplete(g.view(nextItem), stateIndex)
+					}
 				}
-				// create next item
-				// add it to the next stateSet
-				fmt.Println("next item", nextItem)
-				// TODO edge case when last stateIndex
-				nextSet := st.getAt(stateIndex + 1)
-				nextSet.putItem(nextItem)
 				continue
 			}
-			if !item.getNext().isTerminal() {
-				// Predict - the next symbol is Non Terminal
-				nextSymbol := item.getNext().(nonTerminal)
-				// Find all the rules for the symbol put those rules to the current set
-				fmt.Println("Predict - NON TERMINAL")
-				for _, r := range g.getRulesForSymbol(nextSymbol) {
-					nextItem := &earleyItem{
-						rule:  r,
-						dot:   0,
-						index: stateIndex,
-					}
-					set.putItem(nextItem)
+			if stateIndex < in.length() && in.match(d.next, stateIndex) {
+				// Scan - the next symbol is Terminal and matches
+				// add the next item to the next stateSet
+				nextItem := item.advance()
+				nextSet := st.getAt(stateIndex + 1)
+				if nextSet.putItem(g, nextItem) && tracing {
+					tracer.OnScan(g.view(nextItem), stateIndex+1)
 				}
 				continue
 			}
 		}
 
-		fmt.Printf("S\n%v\n", st.String())
+		tracer.OnSetDone(stateIndex, set.length())
+		doneItems += set.length()
 		stateIndex++
 	}
+	return st, nil
 }
 
-func (g *grammar) getRulesForSymbol(s symbol) []*rule {
-	found := []*rule{}
-	for _, r := range g.rules {
-		if r.left == s {
-			found = append(found, r)
-		}
+// check returns a *LimitError if the parse has to stop before looking at the
+// i-th item of S(pos).
+func (cfg *config) check(st *state, pos int, i int, doneItems int) error {
+	items := doneItems + st.getAt(pos).length()
+	if pos+1 < len(*st) {
+		items += st.getAt(pos + 1).length()
+	}
+	// the error is only built when returned: check runs for every item
+	switch {
+	case i%256 == 0 && cfg.ctx.Err() != nil:
+		return &LimitError{Limit: LimitContext, Pos: pos, Items: items, Err: cfg.ctx.Err()}
+	case cfg.maxItemsPerSet > 0 && st.getAt(pos).length() > cfg.maxItemsPerSet:
+		return &LimitError{Limit: LimitItemsPerSet, Max: cfg.maxItemsPerSet, Pos: pos, Items: items}
+	case cfg.maxChartSize > 0 && items > cfg.maxChartSize:
+		return &LimitError{Limit: LimitChartSize, Max: cfg.maxChartSize, Pos: pos, Items: items}
 	}
-	return found
+	return nil
 }
 
-func initializeState(g *grammar, runes []rune) *state {
-	sets := make([]*stateSet, len(runes)+1)
-	for i := range sets {
-		sets[i] = newStateSet()
+// start is the start symbol of the grammar: the left side of its first rule.
+// A grammar without rules has no sentences, and the zero NonTerminal as its
+// start symbol.
+func (g *Grammar) start() NonTerminal {
+	if len(g.rules) == 0 {
+		return NonTerminal{}
 	}
-	sets[0] = newStateSetFromRules(g.rules)
-	s := state(sets)
-	return &s
+	return g.rules[0].left
+}
+
+// nullableSymbols returns the set of non terminals that derive the empty string.
+func (g *Grammar) nullableSymbols() map[NonTerminal]bool {
+	nullable := map[NonTerminal]bool{}
+	changed := true
+	for changed {
+		changed = false
+		for _, r := range g.rules {
+			if nullable[r.left] {
+				continue
+			}
+			all := true
+			for _, s := range r.right {
+				if n, ok := s.(NonTerminal); !ok || !nullable[n] {
+					all = false
+					break
+				}
+			}
+			if all {
+				nullable[r.left] = true
+				changed = true
+			}
+		}
+	}
+	return nullable
 }
 
-func newStateSetFromRules(rules []*rule) *stateSet {
-	items := make([]*earleyItem, len(rules))
-	for i, r := range rules {
-		items[i] = &earleyItem{rule: r, dot: 0, index: 0}
+func initializeState(g *Grammar, n int) *state {
+	s := make(state, n+1)
+	// every start rule is there, whatever the lookahead, for the errors at
+	// the first rune to tell what was expected
+	if id, ok := g.symbolIDs[g.start()]; ok {
+		for _, r := range g.rulesByID[id] {
+			s[0].putItem(g, earleyItem{rule: r, dot: 0, index: 0})
+		}
 	}
-	ss := newStateSet()
-	ss.items = items
-	return ss
+	return &s
 }
 
 func stringToRunes(input string) []rune {
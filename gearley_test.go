@@ -1,53 +1,733 @@
 package gearley
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"unicode"
+
+	"github.com/liuzl/gearley/semiring"
+	"github.com/liuzl/gearley/trace"
 )
 
-var T = NonTerminal("T")
-var A = Terminal('a')
-var B = Terminal('b')
+var T = NewNonTerminal("T")
+var A = NewTerminal('a')
+var B = NewTerminal('b')
 
 func Test_parse_Aabb(t *testing.T) {
-	g := Grammar(
-		Rule(T, A, B),    // T -> 'a' 'b'
-		Rule(T, A, T, B), // T -> 'a' T 'b'
+	g := NewGrammar(
+		NewRule(T, A, B),    // T -> 'a' 'b'
+		NewRule(T, A, T, B), // T -> 'a' T 'b'
+	)
+
+	if err := g.Parse("aabb"); err != nil {
+		t.Errorf("Parse(aabb): %v", err)
+	}
+}
+
+func Test_parse_errors(t *testing.T) {
+	g := NewGrammar(
+		NewRule(T, A, B),    // T -> 'a' 'b'
+		NewRule(T, A, T, B), // T -> 'a' T 'b'
 	)
+	for input, want := range map[string]string{
+		"aab":  "unexpected end of input at 3, expected 'b'",
+		"abb":  "unexpected 'b' at 2",
+		"aacb": "unexpected 'c' at 2, expected 'b' or 'a'",
+		"":     "unexpected end of input at 0, expected 'a'",
+	} {
+		err := g.Parse(input)
+		if err == nil || err.Error() != want {
+			t.Errorf("Parse(%q) = %v, want %v", input, err, want)
+		}
+	}
+}
 
-	g.Parse("aabb")
+func Test_TerminalFunc(t *testing.T) {
+	ID := NewNonTerminal("ID")
+	letter := TerminalFunc("letter", unicode.IsLetter)
+	digit := TerminalFunc("digit", unicode.IsDigit)
+	g := NewGrammar(
+		NewRule(ID, letter),               // ID -> [letter]
+		NewRule(ID, ID, letter),           // ID -> ID [letter]
+		NewRule(ID, ID, digit),            // ID -> ID [digit]
+		NewRule(ID, ID, NewTerminal('_')), // ID -> ID '_'
+	)
+	for _, input := range []string{"x", "héllo_2", "a1b2"} {
+		if err := g.Parse(input); err != nil {
+			t.Errorf("Parse(%q): %v", input, err)
+		}
+	}
+	err := g.Parse("x-1")
+	if want := "unexpected '-' at 1, expected [letter] or [digit] or '_'"; err == nil || err.Error() != want {
+		t.Errorf("Parse(x-1) = %v, want %v", err, want)
+	}
+	if got, want := g.Rules()[2].String(), "ID -> ID [digit]"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
 }
 
 func Test_stateSet_putItem(t *testing.T) {
-	ruleA := Rule(T, A)
-	ruleB := Rule(T, B)
+	ruleA := NewRule(T, A)
+	ruleB := NewRule(T, B)
+	g := NewGrammar(ruleA, ruleB)
 
-	s := newStateSet()
+	var s stateSet
 	if s.length() != 0 {
-		t.Errorf("length not 0: %v", s)
+		t.Errorf("length not 0: %v", s.items)
+	}
+
+	item1a := earleyItem{rule: 0, dot: 0, index: 0}
+	item1b := earleyItem{rule: 0, dot: 0, index: 0}
+	item2a := earleyItem{rule: 1, dot: 0, index: 0}
+
+	if !s.putItem(g, item1a) || s.length() != 1 {
+		t.Errorf("length not 1: %v", s.items)
+	}
+
+	if s.putItem(g, item1b) || s.length() != 1 {
+		t.Errorf("length not 1: %v", s.items)
+	}
+
+	if !s.putItem(g, item2a) || s.length() != 2 {
+		t.Errorf("length not 2: %v", s.items)
+	}
+
+	if got, want := g.view(item2a).String(), "T -> \u25CF'b' (0)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func Test_Score(t *testing.T) {
+	S := NewNonTerminal("S")
+	a := NewTerminal('a')
+	g := NewGrammar(
+		NewRule(S, S, S), // S -> S S
+		NewRule(S, a),    // S -> 'a'
+	)
+
+	// the number of binary trees with n leaves is the Catalan number C(n-1)
+	for input, want := range map[string]uint64{"a": 1, "aa": 1, "aaa": 2, "aaaa": 5, "aaaaa": 14} {
+		if got, err := Score[uint64](g, input, semiring.Counting{}, func(*Rule) uint64 { return 1 }); err != nil || got != want {
+			t.Errorf("count %q = %d, want %d", input, got, want)
+		}
+	}
+	if ok, _ := Score[bool](g, "aab", semiring.Boolean{}, func(*Rule) bool { return true }); ok {
+		t.Errorf("%q recognised", "aab")
+	}
+	if ok, _ := Score[bool](g, "aaa", semiring.Boolean{}, func(*Rule) bool { return true }); !ok {
+		t.Errorf("%q not recognised", "aaa")
+	}
+
+	prob := func(r *Rule) float64 {
+		if r.length() == 2 {
+			return 0.4
+		}
+		return 0.6
+	}
+	// both trees of "aaa" use S -> S S twice and S -> 'a' three times
+	want := 0.4 * 0.4 * 0.6 * 0.6 * 0.6
+	if got, _ := Score[float64](g, "aaa", semiring.Viterbi{}, prob); math.Abs(got-want) > 1e-12 {
+		t.Errorf("viterbi = %v, want %v", got, want)
+	}
+	if got, _ := Score[float64](g, "aaa", semiring.Inside{}, prob); math.Abs(got-2*want) > 1e-12 {
+		t.Errorf("inside = %v, want %v", got, 2*want)
+	}
+	logProb := func(r *Rule) float64 { return math.Log(prob(r)) }
+	if got, _ := Score[float64](g, "aaa", semiring.LogInside{}, logProb); math.Abs(got-math.Log(2*want)) > 1e-12 {
+		t.Errorf("log inside = %v, want %v", got, math.Log(2*want))
+	}
+}
+
+func Test_Score_nullable(t *testing.T) {
+	S := NewNonTerminal("S")
+	E := NewNonTerminal("E")
+	g := NewGrammar(
+		NewRule(S, E, A, E), // S -> E 'a' E
+		NewRule(E),          // E ->
+		NewRule(E, B),       // E -> 'b'
+	)
+	for input, want := range map[string]uint64{"a": 1, "ba": 1, "ab": 1, "bab": 1, "bb": 0} {
+		if got, err := Score[uint64](g, input, semiring.Counting{}, func(*Rule) uint64 { return 1 }); err != nil || got != want {
+			t.Errorf("count %q = %d, want %d", input, got, want)
+		}
+	}
+}
+
+// digit is a terminal kind defined outside of the package's own.
+type digit struct{}
+
+func (digit) IsTerminal() bool  { return true }
+func (digit) String() string    { return "digit" }
+func (digit) Match(r rune) bool { return '0' <= r && r <= '9' }
+
+func Test_custom_Symbol(t *testing.T) {
+	N := NewNonTerminal("N")
+	var g *Grammar = NewGrammar(
+		NewRule(N, digit{}),    // N -> digit
+		NewRule(N, digit{}, N), // N -> digit N
+	)
+	one := func(*Rule) bool { return true }
+	for input, want := range map[string]bool{"7": true, "2024": true, "": false, "20a4": false} {
+		if got, err := Score[bool](g, input, semiring.Boolean{}, one); err != nil || got != want {
+			t.Errorf("recognise %q = %v, want %v", input, got, want)
+		}
+	}
+	var r *Rule = g.Rules()[1]
+	if r.Left() != g.Start() || len(r.Right()) != 2 || r.Right()[1] != Symbol(N) {
+		t.Errorf("unexpected rule %v", r)
+	}
+}
+
+func Test_WriteChartHTML(t *testing.T) {
+	g := NewGrammar(
+		NewRule(T, A, B),    // T -> 'a' 'b'
+		NewRule(T, A, T, B), // T -> 'a' T 'b'
+	)
+	var b strings.Builder
+	if err := g.WriteChartHTML(&b, "ab<"); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`<th>S(0) &#39;a&#39;</th>`,
+		`<th>S(2) &#39;&lt;&#39;</th><th>S(3)</th>`,
+		"<td>T -&gt; " + FLAT_DOT + "&#39;a&#39; &#39;b&#39; (0)</td>",
+		`<td class="completed">T -&gt; &#39;a&#39; &#39;b&#39;` + FLAT_DOT + ` (0)</td>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+}
+
+func Test_WriteChart(t *testing.T) {
+	g := NewGrammar(
+		NewRule(T, A, B),    // T -> 'a' 'b'
+		NewRule(T, A, T, B), // T -> 'a' T 'b'
+	)
+	var b strings.Builder
+	if err := g.WriteChart(&b, "ab"); err != nil {
+		t.Fatal(err)
+	}
+	want := "S(0) 'a'\n" + strings.Repeat("=", 39) + "\n" +
+		"T -> " + FLAT_DOT + "'a' 'b' (0)\n" +
+		"T -> " + FLAT_DOT + "'a' T 'b' (0)\n\n" +
+		"S(1) 'b'\n" + strings.Repeat("=", 39) + "\n" +
+		"T -> 'a'" + FLAT_DOT + "'b' (0)\n" +
+		"T -> 'a'" + FLAT_DOT + "T 'b' (0)\n\n"
+	if got := b.String(); !strings.HasPrefix(got, want) || !strings.Contains(got, "S(2)\n") {
+		t.Errorf("WriteChart() =\n%s\nwant it to start with\n%s", got, want)
+	}
+}
+
+func Test_WithTracer(t *testing.T) {
+	g := NewGrammar(
+		NewRule(T, A, B),    // T -> 'a' 'b'
+		NewRule(T, A, T, B), // T -> 'a' T 'b'
+	)
+	var b strings.Builder
+	if err := g.Parse("ab", WithTracer(trace.NewJSON(&b))); err != nil {
+		t.Fatal(err)
+	}
+	events := []trace.Event{}
+	dec := json.NewDecoder(strings.NewReader(b.String()))
+	for dec.More() {
+		var e trace.Event
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	want := []trace.Event{
+		{Event: "predict", Pos: 0, Item: "T -> " + FLAT_DOT + "'a' 'b' (0)"},
+		{Event: "predict", Pos: 0, Item: "T -> " + FLAT_DOT + "'a' T 'b' (0)"},
+		{Event: "scan", Pos: 1, Item: "T -> 'a'" + FLAT_DOT + "'b' (0)"},
+		{Event: "scan", Pos: 1, Item: "T -> 'a'" + FLAT_DOT + "T 'b' (0)"},
+		{Event: "done", Pos: 0, Size: 2},
+		{Event: "scan", Pos: 2, Item: "T -> 'a' 'b'" + FLAT_DOT + " (0)"},
+		// no prediction of T in S(1): its rules start with 'a', not 'b'
+		{Event: "done", Pos: 1, Size: 2},
+		{Event: "done", Pos: 2, Size: 1},
 	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events:\n%v\nwant\n%v", events, want)
+	}
+}
+
+func Test_predict_lookahead(t *testing.T) {
+	// S -> L S | L, L -> X, and X -> c for every letter c, with a
+	// nullable E before it for X -> E 'z'
+	S := NewNonTerminal("S")
+	L := NewNonTerminal("L")
+	X := NewNonTerminal("X")
+	E := NewNonTerminal("E")
+	rules := []*Rule{NewRule(S, L, S), NewRule(S, L), NewRule(L, X), NewRule(E)}
+	for c := 'a'; c < 'z'; c++ {
+		rules = append(rules, NewRule(X, NewTerminal(c)))
+	}
+	rules = append(rules, NewRule(X, E, NewTerminal('z')))
+	g := NewGrammar(rules...)
+	sizes := &setSizes{}
+	if err := g.Parse("abz", WithTracer(sizes)); err != nil {
+		t.Fatal(err)
+	}
+	// S(0) has the start rules, and the sets the rules of the next letter
+	if want := []int{4, 8, 11, 6}; !reflect.DeepEqual(sizes.sizes, want) {
+		t.Errorf("set sizes %v, want %v", sizes.sizes, want)
+	}
+	// the rules left out are still expected
+	if err := g.Parse("ab!"); err == nil || !strings.Contains(err.Error(), "'y' or 'z'") {
+		t.Errorf("Parse(ab!) = %v", err)
+	}
+}
+
+// setSizes records the sizes of the state sets.
+type setSizes struct {
+	trace.Nop
+	sizes []int
+}
 
-	item1a := &earleyItem{rule: ruleA, dot: 0, index: 0}
-	item1b := &earleyItem{rule: ruleA, dot: 0, index: 0}
-	item2a := &earleyItem{rule: ruleB, dot: 0, index: 0}
-	//item2b := &earleyItem{rule: Rule(T, B), dot: 0, index: 0}
+func (s *setSizes) OnSetDone(pos int, size int) {
+	s.sizes = append(s.sizes, size)
+}
 
-	s.putItem(item1a)
-	if s.length() != 1 {
-		t.Errorf("length not 1: %v", s)
+func Test_Grammar_concurrent(t *testing.T) {
+	S := NewNonTerminal("S")
+	g := NewGrammar(
+		NewRule(S, S, S),             // S -> S S
+		NewRule(S, NewTerminal('a')), // S -> 'a'
+	)
+	one := func(*Rule) uint64 { return 1 }
+	catalan := []uint64{1, 1, 2, 5, 14, 42, 132, 429}
+	var wg sync.WaitGroup
+	errs := make(chan string, 300)
+	for i := 0; i < 300; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			input := strings.Repeat("a", n)
+			if err := g.Parse(input); err != nil {
+				errs <- err.Error()
+			}
+			if got, _ := Score[uint64](g, input, semiring.Counting{}, one); got != catalan[n-1] {
+				errs <- fmt.Sprintf("count %q = %d, want %d", input, got, catalan[n-1])
+			}
+			if g.Parse(input+"b") == nil {
+				errs <- input + "b accepted"
+			}
+		}(i%len(catalan) + 1)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
 	}
+}
 
-	s.putItem(item1b)
-	if s.length() != 1 {
-		t.Errorf("length not 1: %v", s)
+func Test_ParseContext(t *testing.T) {
+	S := NewNonTerminal("S")
+	g := NewGrammar(
+		NewRule(S, S, S),             // S -> S S
+		NewRule(S, NewTerminal('a')), // S -> 'a'
+	)
+	input := strings.Repeat("a", 50)
+	if err := g.ParseContext(context.Background(), input); err != nil {
+		t.Fatalf("ParseContext: %v", err)
 	}
 
-	s.putItem(item2a)
-	if s.length() != 2 {
-		t.Errorf("length not 2: %v", s)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := g.ParseContext(ctx, input)
+	var limit *LimitError
+	if !errors.As(err, &limit) || limit.Limit != LimitContext || !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: %v", err)
 	}
 
-	//s.putItem(item2b)
-	//if s.length() != 2 {
-	//	t.Errorf("length not 2: %v", s)
-	//}
+	err = g.Parse(input, WithMaxItemsPerSet(20))
+	if !errors.As(err, &limit) || limit.Limit != LimitItemsPerSet || limit.Max != 20 || limit.Pos == 0 {
+		t.Errorf("items per set: %#v", err)
+	}
+	err = g.Parse(input, WithMaxChartSize(500))
+	if !errors.As(err, &limit) || limit.Limit != LimitChartSize || limit.Items <= 500 {
+		t.Errorf("chart size: %#v", err)
+	}
+	if want := fmt.Sprintf("parse stopped at %d with %d items: more than 500 chart size", limit.Pos, limit.Items); err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+	if err := g.Parse(input, WithMaxItemsPerSet(1000), WithMaxChartSize(100000)); err != nil {
+		t.Errorf("limits too low: %v", err)
+	}
+}
+
+func Test_Expected(t *testing.T) {
+	g := NewGrammar(
+		NewRule(T, A, B),    // T -> 'a' 'b'
+		NewRule(T, A, T, B), // T -> 'a' T 'b'
+	)
+	expected, err := g.Expected("aa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(expected); got != "['b' 'a']" {
+		t.Errorf("Expected(aa) = %s", got)
+	}
+	if expected, err := g.Expected("ab"); err != nil || len(expected) != 0 {
+		t.Errorf("Expected(ab) = %v, %v", expected, err)
+	}
+	if _, err := g.Expected("b"); err == nil || err.Error() != "unexpected 'b' at 0, expected 'a'" {
+		t.Errorf("Expected(b) error = %v", err)
+	}
+
+	// two matchers of the same name are two terminals
+	digit := TerminalFunc("x", unicode.IsDigit)
+	letter := TerminalFunc("x", unicode.IsLetter)
+	g = NewGrammar(NewRule(T, digit), NewRule(T, letter), NewRule(T, digit, digit))
+	if expected, err := g.Expected(""); err != nil || len(expected) != 2 || expected[0] != digit || expected[1] != letter {
+		t.Errorf("Expected() = %v, %v", expected, err)
+	}
+}
+
+func Test_Trees(t *testing.T) {
+	S := NewNonTerminal("S")
+	E := NewNonTerminal("E")
+	g := NewGrammar(
+		NewRule(S, S, S), // S -> S S
+		NewRule(S, A, E), // S -> 'a' E
+		NewRule(E),       // E ->
+	)
+	trees, err := g.Trees("aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, tree := range trees {
+		got = append(got, tree.String())
+	}
+	want := []string{
+		"(S (S (S 'a' (E)) (S 'a' (E))) (S 'a' (E)))",
+		"(S (S 'a' (E)) (S (S 'a' (E)) (S 'a' (E))))",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Trees(aaa) = %q, want %q", got, want)
+	}
+	if e := trees[1].Children[1].Children[0].Children[1]; e.Start != 2 || e.End != 2 {
+		t.Errorf("E spans %d-%d, want 2-2", e.Start, e.End)
+	}
+
+	// a cycle: the trees are the ones Score counts
+	g = NewGrammar(append(g.Rules(), NewRule(E, E))...) // E -> E
+	trees, err = g.Trees("aaa")
+	if count, _ := Score[uint64](g, "aaa", semiring.Counting{}, func(*Rule) uint64 { return 1 }); err != nil || uint64(len(trees)) != count {
+		t.Errorf("Trees(aaa) with a cycle = %d trees, %v, Score counts %d", len(trees), err, count)
+	}
+
+	trees, err = g.Trees("aaaa", WithMaxTrees(3))
+	var limit *LimitError
+	if len(trees) != 3 || !errors.As(err, &limit) || limit.Limit != LimitTrees {
+		t.Errorf("Trees(aaaa) with 3 at most = %d trees, %v", len(trees), err)
+	}
+	if _, err := g.Trees("b"); err == nil || err.Error() != "unexpected 'b' at 0, expected 'a'" {
+		t.Errorf("Trees(b) error = %v", err)
+	}
+}
+
+func Test_Forest(t *testing.T) {
+	S := NewNonTerminal("S")
+	E := NewNonTerminal("E")
+	g := NewGrammar(
+		NewRule(S, S, S), // S -> S S
+		NewRule(S, A, E), // S -> 'a' E
+		NewRule(E),       // E ->
+		NewRule(E, B),    // E -> 'b'
+	)
+	p := NewEarleyParser(g)
+	for _, input := range []string{"a", "aba", "aaaaa", "abaab"} {
+		if err := p.Recognize(input); err != nil {
+			t.Errorf("Recognize(%q): %v", input, err)
+		}
+		forest, err := p.Parse(input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", input, err)
+		}
+		count, _ := Score[uint64](g, input, semiring.Counting{}, func(*Rule) uint64 { return 1 })
+		if got := forest.Count(); got != count {
+			t.Errorf("%q: Count() = %d, want %d", input, got, count)
+		}
+		trees, _ := g.Trees(input)
+		want := []string{}
+		for _, tree := range trees {
+			want = append(want, tree.String())
+		}
+		got := []string{}
+		for _, tree := range forest.Trees(0) {
+			got = append(got, tree.String())
+		}
+		sort.Strings(want)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: Trees(0) = %q, want %q", input, got, want)
+		}
+		if n := len(forest.Trees(3)); n > 3 {
+			t.Errorf("%q: Trees(3) = %d trees", input, n)
+		}
+	}
+	// one node per symbol and span, shared by the trees
+	forest, _ := p.Parse("aaaaa")
+	split := map[int]*ForestNode{}
+	for _, fam := range forest.Root.Families {
+		split[fam.Children[0].End] = fam.Children[0]
+	}
+	if s01, s02 := split[1], split[2]; s01 == nil || s02 == nil || s02.Families[0].Children[0] != s01 {
+		t.Errorf("S 0-1 is not shared")
+	}
+	if _, err := p.Parse("b"); err == nil || err.Error() != "unexpected 'b' at 0, expected 'a'" {
+		t.Errorf("Parse(b) error = %v", err)
+	}
+}
+
+func Test_Tree_encodings(t *testing.T) {
+	g := NewGrammar(
+		NewRule(T, A, B),    // T -> 'a' 'b'
+		NewRule(T, A, T, B), // T -> 'a' T 'b'
+	)
+	trees, err := g.Trees("aabb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := trees[0]
+	var b strings.Builder
+	tree.Print(&b)
+	want := "T -> 'a' T 'b' [0-4]\n  'a'\n  T -> 'a' 'b' [1-3]\n    'a'\n    'b'\n  'b'\n"
+	if got := b.String(); got != want {
+		t.Errorf("Print() =\n%s\nwant\n%s", got, want)
+	}
+	if got, want := tree.PTB(), "(T\n  a\n  (T a b)\n  b)\n"; got != want {
+		t.Errorf("PTB() = %q, want %q", got, want)
+	}
+	data, err := json.Marshal(tree.Children[1])
+	if want := `{"symbol":"T","span":[1,3],"children":[{"token":"a","span":[1,2]},{"token":"b","span":[2,3]}]}`; err != nil || string(data) != want {
+		t.Errorf("MarshalJSON() = %s, %v, want %s", data, err, want)
+	}
+	b.Reset()
+	if err := tree.WriteDOT(&b); err != nil || !strings.Contains(b.String(), `n0 [label="T [0-4]"];`) ||
+		!strings.Contains(b.String(), `n1 [shape=box, label="a"];`) {
+		t.Errorf("WriteDOT() = %s, %v", b.String(), err)
+	}
+
+	S := NewNonTerminal("S")
+	g = NewGrammar(NewRule(S, S, S), NewRule(S, A)) // S -> S S | 'a'
+	forest, err := NewEarleyParser(g).Parse("aaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ambiguities := forest.Ambiguities()
+	if len(ambiguities) != 1 || ambiguities[0] != forest.Root || forest.Root.String() != "S [0-3]" {
+		t.Errorf("Ambiguities() = %v", ambiguities)
+	}
+	b.Reset()
+	if err := forest.WriteDOT(&b); err != nil || !strings.Contains(b.String(), `"S_0_3" [label="S [0-3]", color=red`) ||
+		!strings.Contains(b.String(), `t2 [shape=box, label="a"];`) {
+		t.Errorf("WriteDOT() = %s, %v", b.String(), err)
+	}
+}
+
+func Test_Parse_tokens(t *testing.T) {
+	type word struct{ text, tag string }
+	tags := []*Matcher{TerminalFunc("DET", nil), TerminalFunc("N", nil), TerminalFunc("V", nil)}
+	g, err := ParseBNF(`
+		<S>  ::= <NP> <VP>
+		<NP> ::= [DET] [N] | [N]
+		<VP> ::= [V] <NP> | [V]
+	`, tags...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := func(s Symbol, w word) bool {
+		m, ok := s.(*Matcher)
+		return ok && m.Name() == w.tag
+	}
+	sentence := []word{{"the", "DET"}, {"cat", "N"}, {"sees", "V"}, {"mice", "N"}}
+	if err := Parse(g, sentence, match); err != nil {
+		t.Errorf("Parse(%v): %v", sentence, err)
+	}
+	err = Parse(g, []word{{"the", "DET"}, {"sees", "V"}}, match)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Pos != 1 || pe.Token != (word{"sees", "V"}) {
+		t.Fatalf("Parse() = %v, want a *ParseError at 1", err)
+	}
+	if want := "unexpected {sees V} at 1, expected [N]"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	err = Parse(g, []word{{"the", "DET"}}, match)
+	if want := "unexpected end of input at 1, expected [N]"; err == nil || err.Error() != want {
+		t.Errorf("Parse() = %v, want %v", err, want)
+	}
+}
+
+func Test_Constituents(t *testing.T) {
+	g, err := ParseBNF(`
+		<S> ::= <N> "+" <N>
+		<N> ::= <D> | <N> <D>
+		<D> ::= [digit]
+	`, TerminalFunc("digit", unicode.IsDigit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := func(cs []Constituent) string {
+		parts := []string{}
+		for _, c := range cs {
+			parts = append(parts, fmt.Sprintf("%s %d-%d", c.Symbol, c.Start, c.End))
+		}
+		return strings.Join(parts, ", ")
+	}
+	cs, err := g.Constituents("12+")
+	if want := "N 0-1, D 0-1, N 0-2, D 1-2"; err != nil || format(cs) != want {
+		t.Errorf("Constituents(12+) = %s, %v, want %s", format(cs), err, want)
+	}
+	for input, want := range map[string]string{
+		"12+3":    "S 0-4",
+		"12":      "N 0-2",
+		"1+2x3+4": "S 0-3, S 4-7",
+		"x1+2y34": "S 1-4, N 5-7",
+		"xy":      "",
+	} {
+		cs, err := g.Chunks(input)
+		if err != nil || format(cs) != want {
+			t.Errorf("Chunks(%q) = %s, %v, want %s", input, format(cs), err, want)
+		}
+	}
+	if _, err := g.Chunks("1+2x3+4", WithMaxChartSize(5)); err == nil {
+		t.Errorf("Chunks() with a limit: no error")
+	}
+}
+
+func Test_FindAll(t *testing.T) {
+	// dates such as 2024-01-31
+	g, err := ParseBNF(`
+		<DATE>  ::= <D> <D> <D> <D> "-" <D> <D> "-" <D> <D>
+		<D>     ::= [digit]
+	`, TerminalFunc("digit", unicode.IsDigit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "from 2024-01-31 to 2024-02-29, not 24-1-1"
+	matches, err := g.FindAll(input)
+	want := []Match{{5, 15}, {19, 29}}
+	if err != nil || !reflect.DeepEqual(matches, want) {
+		t.Errorf("FindAll() = %v, %v, want %v", matches, err, want)
+	}
+	runes := []rune(input)
+	for _, m := range matches {
+		if err := g.Parse(string(runes[m.Start:m.End])); err != nil {
+			t.Errorf("match %v: %v", m, err)
+		}
+	}
+
+	// numbers: every run of digits is a match, and so are its parts
+	g, err = ParseBNF(`
+		<N> ::= <N> [digit] | [digit]
+	`, TerminalFunc("digit", unicode.IsDigit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err = g.FindAll("a12b3")
+	if want := []Match{{1, 3}, {4, 5}}; err != nil || !reflect.DeepEqual(matches, want) {
+		t.Errorf("FindAll() = %v, %v, want %v", matches, err, want)
+	}
+	matches, err = g.FindAll("a12b3", WithAllMatches())
+	if want := []Match{{1, 2}, {1, 3}, {2, 3}, {4, 5}}; err != nil || !reflect.DeepEqual(matches, want) {
+		t.Errorf("FindAll(WithAllMatches) = %v, %v, want %v", matches, err, want)
+	}
+}
+
+func Test_Status(t *testing.T) {
+	// a list of a's in parentheses
+	g, err := ParseBNF(`
+		<L> ::= "(" <A> ")"
+		<A> ::= <A> "a" | ""
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for input, want := range map[string]Status{
+		"(aa)": Complete,
+		"()":   Complete,
+		"":     Incomplete,
+		"(a":   Incomplete,
+		"(a)a": Invalid,
+		"a":    Invalid,
+	} {
+		if got, err := g.Status(input); err != nil || got != want {
+			t.Errorf("Status(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	if _, err := g.Status("(aaaa)", WithMaxChartSize(3)); err == nil {
+		t.Errorf("Status() with a limit: no error")
+	}
+}
+
+func Test_empty_grammar(t *testing.T) {
+	fromJSON, err := UnmarshalGrammar([]byte(`{"rules":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range []*Grammar{NewGrammar(), fromJSON} {
+		var pe *ParseError
+		if err := g.Parse(""); !errors.As(err, &pe) || !pe.EOF {
+			t.Errorf("Parse() = %v, want a *ParseError", err)
+		}
+		if err := g.Parse("a"); !errors.As(err, &pe) || pe.Pos != 0 {
+			t.Errorf("Parse(a) = %v, want a *ParseError at 0", err)
+		}
+		if _, err := g.Trees("a"); err == nil {
+			t.Errorf("Trees(a): no error")
+		}
+		if s, err := g.Status("a"); err != nil || s != Invalid {
+			t.Errorf("Status(a) = %v, %v, want invalid", s, err)
+		}
+		if m, err := g.FindAll("ab"); err != nil || len(m) != 0 {
+			t.Errorf("FindAll(ab) = %v, %v", m, err)
+		}
+		if cs, err := g.Constituents("ab"); err != nil || len(cs) != 0 {
+			t.Errorf("Constituents(ab) = %v, %v", cs, err)
+		}
+		if cs, err := g.Chunks("ab"); err != nil || len(cs) != 0 {
+			t.Errorf("Chunks(ab) = %v, %v", cs, err)
+		}
+		if n, err := Score[uint64](g, "", semiring.Counting{}, func(*Rule) uint64 { return 1 }); err != nil || n != 0 {
+			t.Errorf("Score() = %d, want 0", n)
+		}
+	}
+}
+
+func Test_Score_limits(t *testing.T) {
+	S := NewNonTerminal("S")
+	g := NewGrammar(
+		NewRule(S, S, S),             // S -> S S
+		NewRule(S, NewTerminal('a')), // S -> 'a'
+	)
+	one := func(*Rule) uint64 { return 1 }
+	// a parse that gives up is told apart from a rejected input
+	n, err := Score[uint64](g, "aaaa", semiring.Counting{}, one, WithMaxChartSize(5))
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != LimitChartSize || n != 0 {
+		t.Errorf("Score() with a limit = %d, %v, want a *LimitError", n, err)
+	}
+	if n, err := Score[uint64](g, "ab", semiring.Counting{}, one); err != nil || n != 0 {
+		t.Errorf("Score(ab) = %d, %v, want 0, nil", n, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ScoreContext[uint64](ctx, g, "aaaa", semiring.Counting{}, one)
+	if !errors.As(err, &le) || le.Limit != LimitContext || !errors.Is(err, context.Canceled) {
+		t.Errorf("ScoreContext() canceled = %v", err)
+	}
 }
@@ -0,0 +1,287 @@
+package gearley
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// GenerateOption tunes Generate.
+type GenerateOption func(*generator)
+
+// DefaultMaxDepth is how deep Generate nests rules unless told otherwise.
+const DefaultMaxDepth = 12
+
+type generator struct {
+	g         *Grammar
+	rnd       *rand.Rand
+	maxDepth  int
+	weight    func(*Rule) float64
+	coverage  bool
+	mutations float64
+	alphabet  []rune
+
+	// minDepth is the least depth each non terminal derives a string in;
+	// non terminals deriving no string are missing
+	minDepth map[NonTerminal]int
+	// used holds the rules the sentences so far were derived with
+	used map[*Rule]bool
+}
+
+// WithRand draws the random choices of Generate from rnd.
+func WithRand(rnd *rand.Rand) GenerateOption {
+	return func(gen *generator) {
+		gen.rnd = rnd
+	}
+}
+
+// WithMaxDepth nests at most n rules in a sentence.
+func WithMaxDepth(n int) GenerateOption {
+	return func(gen *generator) {
+		gen.maxDepth = n
+	}
+}
+
+// WithWeights picks the rules of a non terminal in proportion to weight.
+// Rules weighing 0 are only picked when nothing else fits the depth.
+func WithWeights(weight func(*Rule) float64) GenerateOption {
+	return func(gen *generator) {
+		gen.weight = weight
+	}
+}
+
+// WithCoverage prefers the rules no sentence used yet, and generates more
+// sentences than asked for until every rule reachable within the maximal
+// depth has been used.
+func WithCoverage() GenerateOption {
+	return func(gen *generator) {
+		gen.coverage = true
+	}
+}
+
+// WithMutations turns a share rate of the sentences into near misses: one
+// rune of the grammar is inserted, removed or replaced. A near miss may still
+// happen to be a sentence.
+func WithMutations(rate float64) GenerateOption {
+	return func(gen *generator) {
+		gen.mutations = rate
+	}
+}
+
+// WithAlphabet sets the runes tried for the terminals other than Terminal,
+// such as matchers. It defaults to the printable ASCII runes.
+func WithAlphabet(runes ...rune) GenerateOption {
+	return func(gen *generator) {
+		gen.alphabet = runes
+	}
+}
+
+// Generate returns n random sentences of the grammar.
+func Generate(g *Grammar, n int, opts ...GenerateOption) ([]string, error) {
+	gen := &generator{
+		g:        g,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		maxDepth: DefaultMaxDepth,
+		used:     map[*Rule]bool{},
+	}
+	for r := ' '; r <= '~'; r++ {
+		gen.alphabet = append(gen.alphabet, r)
+	}
+	for _, opt := range opts {
+		opt(gen)
+	}
+	if len(g.rules) == 0 {
+		return nil, fmt.Errorf("gearley: generate: no rules")
+	}
+	gen.minDepth = g.minDepths()
+	if d, ok := gen.minDepth[g.start()]; !ok || d > gen.maxDepth {
+		return nil, fmt.Errorf("gearley: generate: %v derives no string within depth %d",
+			g.start(), gen.maxDepth)
+	}
+
+	sentences := []string{}
+	reachable := gen.reachableRules()
+	// sentences in a row that used no new rule
+	stale := 0
+	for len(sentences) < n ||
+		(gen.coverage && len(gen.used) < reachable && stale < len(g.rules)) {
+		covered := len(gen.used)
+		sentence, err := gen.derive(g.start(), gen.maxDepth, nil)
+		if err != nil {
+			return nil, err
+		}
+		if gen.mutations > 0 && gen.rnd.Float64() < gen.mutations {
+			sentence = gen.mutate(sentence)
+		}
+		sentences = append(sentences, string(sentence))
+		if len(gen.used) == covered {
+			stale++
+		} else {
+			stale = 0
+		}
+	}
+	return sentences, nil
+}
+
+// reachableRules returns how many rules a sentence within the maximal depth
+// may use.
+func (gen *generator) reachableRules() int {
+	// the most depth left when reaching each non terminal
+	left := map[NonTerminal]int{gen.g.start(): gen.maxDepth}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range gen.g.rules {
+			d, ok := ruleDepth(r, gen.minDepth)
+			if depth, seen := left[r.left]; !ok || !seen || d > depth {
+				continue
+			}
+			for _, s := range r.right {
+				if n, ok := s.(NonTerminal); ok {
+					if depth, seen := left[n]; !seen || depth < left[r.left]-1 {
+						left[n] = left[r.left] - 1
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	reachable := map[*Rule]bool{}
+	for _, r := range gen.g.rules {
+		if d, ok := ruleDepth(r, gen.minDepth); ok && d <= left[r.left] {
+			if _, seen := left[r.left]; seen {
+				reachable[r] = true
+			}
+		}
+	}
+	return len(reachable)
+}
+
+// minDepths returns the least depth each non terminal derives a string in.
+func (g *Grammar) minDepths() map[NonTerminal]int {
+	minDepth := map[NonTerminal]int{}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range g.rules {
+			d, ok := ruleDepth(r, minDepth)
+			if old, seen := minDepth[r.left]; ok && (!seen || d < old) {
+				minDepth[r.left] = d
+				changed = true
+			}
+		}
+	}
+	return minDepth
+}
+
+// ruleDepth returns the least depth r derives a string in, if it does.
+func ruleDepth(r *Rule, minDepth map[NonTerminal]int) (int, bool) {
+	depth := 1
+	for _, s := range r.right {
+		if n, ok := s.(NonTerminal); ok {
+			d, ok := minDepth[n]
+			if !ok {
+				return 0, false
+			}
+			if d+1 > depth {
+				depth = d + 1
+			}
+		}
+	}
+	return depth, true
+}
+
+// derive appends a random string of s, nesting at most depth rules, to
+// sentence.
+func (gen *generator) derive(s Symbol, depth int, sentence []rune) ([]rune, error) {
+	switch s := s.(type) {
+	case Terminal:
+		return append(sentence, s.value), nil
+	case NonTerminal:
+		r := gen.choose(s, depth)
+		gen.used[r] = true
+		var err error
+		for _, sym := range r.right {
+			if sentence, err = gen.derive(sym, depth-1, sentence); err != nil {
+				return nil, err
+			}
+		}
+		return sentence, nil
+	}
+	candidates := []rune{}
+	for _, r := range gen.alphabet {
+		if s.Match(r) {
+			candidates = append(candidates, r)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("gearley: generate: no rune of the alphabet matches %v", s)
+	}
+	return append(sentence, candidates[gen.rnd.Intn(len(candidates))]), nil
+}
+
+// choose picks a rule of n deriving a string within depth.
+func (gen *generator) choose(n NonTerminal, depth int) *Rule {
+	candidates := []*Rule{}
+	for _, r := range gen.g.rulesBySymbol[n] {
+		if d, ok := ruleDepth(r, gen.minDepth); ok && d <= depth {
+			candidates = append(candidates, r)
+		}
+	}
+	if gen.coverage {
+		unused := []*Rule{}
+		for _, r := range candidates {
+			if !gen.used[r] {
+				unused = append(unused, r)
+			}
+		}
+		if len(unused) > 0 {
+			candidates = unused
+		}
+	}
+	if gen.weight != nil {
+		total := 0.0
+		for _, r := range candidates {
+			total += gen.weight(r)
+		}
+		if total > 0 {
+			x := gen.rnd.Float64() * total
+			for _, r := range candidates {
+				if x -= gen.weight(r); x < 0 {
+					return r
+				}
+			}
+		}
+	}
+	return candidates[gen.rnd.Intn(len(candidates))]
+}
+
+// mutate inserts, removes or replaces one rune of sentence.
+func (gen *generator) mutate(sentence []rune) []rune {
+	runes := []rune{}
+	for _, r := range gen.g.rules {
+		for _, s := range r.right {
+			if t, ok := s.(Terminal); ok {
+				runes = append(runes, t.value)
+			}
+		}
+	}
+	if len(runes) == 0 {
+		runes = gen.alphabet
+	}
+	if len(runes) == 0 {
+		return sentence
+	}
+	c := runes[gen.rnd.Intn(len(runes))]
+	i := gen.rnd.Intn(len(sentence) + 1)
+	mutated := append([]rune{}, sentence[:i]...)
+	switch op := gen.rnd.Intn(3); {
+	case op == 0 || i == len(sentence):
+		mutated = append(mutated, c)
+		mutated = append(mutated, sentence[i:]...)
+	case op == 1:
+		mutated = append(mutated, sentence[i+1:]...)
+	default:
+		mutated = append(mutated, c)
+		mutated = append(mutated, sentence[i+1:]...)
+	}
+	return mutated
+}
@@ -0,0 +1,99 @@
+package gearley
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func Test_Generate(t *testing.T) {
+	E := NewNonTerminal("E")
+	N := NewNonTerminal("N")
+	g := NewGrammar(
+		NewRule(E, E, NewTerminal('+'), E),                // E -> E '+' E
+		NewRule(E, NewTerminal('('), E, NewTerminal(')')), // E -> '(' E ')'
+		NewRule(E, N), // E -> N
+		NewRule(N, TerminalFunc("digit", unicode.IsDigit)), // N -> [digit]
+		NewRule(N, NewTerminal('x')),                       // N -> 'x'
+	)
+	sentences, err := Generate(g, 50, WithRand(rand.New(rand.NewSource(1))), WithMaxDepth(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sentences) != 50 {
+		t.Errorf("%d sentences, want 50", len(sentences))
+	}
+	for _, s := range sentences {
+		if err := g.Parse(s); err != nil {
+			t.Errorf("Parse(%q): %v", s, err)
+		}
+		nesting, deepest := 0, 0
+		for _, c := range s {
+			if c == '(' {
+				nesting++
+			} else if c == ')' {
+				nesting--
+			}
+			if nesting > deepest {
+				deepest = nesting
+			}
+		}
+		if deepest > 4 {
+			t.Errorf("%q nests deeper than 6 rules", s)
+		}
+	}
+
+	// every rule is used once, starting with the first sentence
+	sentences, err = Generate(g, 1, WithRand(rand.New(rand.NewSource(1))), WithCoverage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := strings.Join(sentences, " ")
+	for _, c := range "+()x" {
+		if !strings.ContainsRune(all, c) {
+			t.Errorf("%q: no %q", sentences, c)
+		}
+	}
+	if !strings.ContainsAny(all, "0123456789") {
+		t.Errorf("%q: no digit", sentences)
+	}
+
+	// rules weighing 0 are only used to stop the recursion
+	onlyX := func(r *Rule) float64 {
+		if s := r.right[0]; s == Symbol(NewTerminal('x')) || s == Symbol(N) {
+			return 1
+		}
+		return 0
+	}
+	sentences, err = Generate(g, 10, WithRand(rand.New(rand.NewSource(1))), WithWeights(onlyX))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range sentences {
+		if s != "x" {
+			t.Errorf("weighted sentence %q, want x", s)
+		}
+	}
+
+	sentences, err = Generate(g, 20, WithRand(rand.New(rand.NewSource(1))), WithMutations(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected := 0
+	for _, s := range sentences {
+		if g.Parse(s) != nil {
+			rejected++
+		}
+	}
+	if rejected == 0 {
+		t.Errorf("no mutation of %q is rejected", sentences)
+	}
+
+	if _, err := Generate(g, 1, WithMaxDepth(1)); err == nil {
+		t.Errorf("Generate at depth 1 succeeded")
+	}
+	if _, err := Generate(g, 1, WithAlphabet('a'), WithCoverage()); err == nil {
+		t.Errorf("Generate without digits succeeded")
+	}
+}
@@ -1,9 +1,86 @@
 package gearley
 
-type grammar struct {
-	rules []*rule
+// Grammar is an ordered list of rules; the left side of the first rule is
+// the start symbol.
+//
+// A Grammar is immutable once built, and compiled for parsing: it may be
+// shared by any number of goroutines parsing in parallel.
+type Grammar struct {
+	rules []*Rule
+	// rulesBySymbol indexes the rules by their left side
+	rulesBySymbol map[NonTerminal][]*Rule
+	// nullable holds the non terminals deriving the empty string
+	nullable map[NonTerminal]bool
+
+	// the compiled form of the rules: non terminals are numbered in order of
+	// appearance, and the dotted rules of rule i are numbered from
+	// dottedBase[i] on
+	symbolIDs   map[NonTerminal]int32
+	rulesByID   [][]int32
+	nullableIDs []bool
+	dottedRules []dottedRule
+	dottedBase  []int32
+
+	// the terminals of the rules, numbered, and for each rule the set of
+	// the terminals it may start with and whether it derives the empty
+	// string, see compileLeftCorners
+	terminals    []Symbol
+	ruleFirst    []bitset
+	ruleNullable []bool
+}
+
+func NewGrammar(rules ...*Rule) *Grammar {
+	g := &Grammar{
+		rules:         make([]*Rule, len(rules)),
+		rulesBySymbol: map[NonTerminal][]*Rule{},
+		symbolIDs:     map[NonTerminal]int32{},
+	}
+	// rules are immutable: sharing them with the caller is fine
+	for i, r := range rules {
+		g.rules[i] = r
+		g.rulesBySymbol[r.left] = append(g.rulesBySymbol[r.left], r)
+	}
+	g.nullable = g.nullableSymbols()
+	g.compile()
+	g.compileLeftCorners()
+	return g
+}
+
+// compile numbers the non terminals and the dotted rules of g.
+func (g *Grammar) compile() {
+	id := func(n NonTerminal) int32 {
+		if _, ok := g.symbolIDs[n]; !ok {
+			g.symbolIDs[n] = int32(len(g.rulesByID))
+			g.rulesByID = append(g.rulesByID, nil)
+			g.nullableIDs = append(g.nullableIDs, g.nullable[n])
+		}
+		return g.symbolIDs[n]
+	}
+	g.dottedBase = make([]int32, len(g.rules))
+	for i, r := range g.rules {
+		left := id(r.left)
+		g.rulesByID[left] = append(g.rulesByID[left], int32(i))
+		g.dottedBase[i] = int32(len(g.dottedRules))
+		for dot := 0; dot <= len(r.right); dot++ {
+			d := dottedRule{rule: r, left: left, nextID: -1}
+			if dot < len(r.right) {
+				d.next = r.right[dot]
+				if n, ok := d.next.(NonTerminal); ok {
+					d.nextID = id(n)
+				}
+			}
+			g.dottedRules = append(g.dottedRules, d)
+		}
+	}
+}
+
+// Rules returns the rules of the grammar, in order.
+func (g *Grammar) Rules() []*Rule {
+	return append([]*Rule(nil), g.rules...)
 }
 
-func Grammar(rules ...*rule) *grammar {
-	return &grammar{rules: rules}
+// Start returns the start symbol of the grammar, the zero NonTerminal for a
+// grammar without rules.
+func (g *Grammar) Start() NonTerminal {
+	return g.start()
 }
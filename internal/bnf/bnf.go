@@ -0,0 +1,137 @@
+// Package bnf holds what the BNF readers and writers of the parsers share:
+// the lexer of the BNF form, and the escaping of the names in brackets.
+//
+// A backslash escapes a backslash or closing bracket in a name, and \n stands
+// for a newline. Comments run from # to the end of the line.
+package bnf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kind is the kind of a token.
+type Kind int
+
+const (
+	NonTerminal Kind = iota // a name in angle brackets
+	String                  // a Go quoted string
+	Matcher                 // a name in square brackets
+	Define                  // ::=
+	Bar                     // |
+)
+
+// Token is a token of the BNF form, with the line it is on. Text is the name
+// of a non terminal or matcher, unescaped, or the unquoted string.
+type Token struct {
+	Kind Kind
+	Text string
+	Line int
+}
+
+// Lex splits text into tokens. Its errors tell the line, for the parsers to
+// prefix with their package: "line 3: bad string".
+func Lex(text string) ([]Token, error) {
+	tokens := []Token{}
+	line := 1
+	for len(text) > 0 {
+		c := text[0]
+		switch {
+		case c == '\n':
+			line++
+			text = text[1:]
+		case unicode.IsSpace(rune(c)):
+			text = text[1:]
+		case c == '#':
+			end := strings.IndexByte(text, '\n')
+			if end < 0 {
+				end = len(text)
+			}
+			text = text[end:]
+		case c == '|':
+			tokens = append(tokens, Token{Kind: Bar, Line: line})
+			text = text[1:]
+		case strings.HasPrefix(text, "::="):
+			tokens = append(tokens, Token{Kind: Define, Line: line})
+			text = text[3:]
+		case c == '<' || c == '[':
+			closing := map[byte]byte{'<': '>', '[': ']'}[c]
+			name, n, ok := readName(text, closing)
+			if !ok {
+				return nil, fmt.Errorf("line %d: unterminated %c", line, c)
+			}
+			kind := NonTerminal
+			if c == '[' {
+				kind = Matcher
+			}
+			tokens = append(tokens, Token{Kind: kind, Text: name, Line: line})
+			text = text[n:]
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad string", line)
+			}
+			s, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, Token{Kind: String, Text: s, Line: line})
+			text = text[len(quoted):]
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", line, c)
+		}
+	}
+	return tokens, nil
+}
+
+// Name returns name between the brackets opening and closing, escaped for Lex
+// to read back.
+func Name(opening, closing byte, name string) string {
+	var b strings.Builder
+	b.WriteByte(opening)
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '\\', closing:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(closing)
+	return b.String()
+}
+
+// readName reads the name in brackets text starts with, up to closing on the
+// same line, and returns it unescaped with the length it took in text.
+func readName(text string, closing byte) (string, int, bool) {
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == closing:
+			return b.String(), i + 1, true
+		case c == '\n':
+			return "", 0, false
+		case c == '\\' && i+1 < len(text) && text[i+1] == 'n':
+			b.WriteByte('\n')
+			i++
+		case c == '\\' && i+1 < len(text) && (text[i+1] == '\\' || text[i+1] == closing):
+			b.WriteByte(text[i+1])
+			i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+// ByName returns the matchers by the name the function name gives them, for
+// the readers to look up the names in square brackets.
+func ByName[M any](matchers []M, name func(M) string) map[string]M {
+	byName := map[string]M{}
+	for _, m := range matchers {
+		byName[name(m)] = m
+	}
+	return byName
+}
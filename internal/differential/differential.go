@@ -0,0 +1,62 @@
+// Package differential holds what the differential tests of the parsers
+// share: a corpus of grammars, and the inputs to run through two parsers of
+// the same grammar to check that they agree.
+//
+// The grammars are the .bnf files of testdata, named after their file. They
+// are acyclic: their inputs have a finite number of trees.
+package differential
+
+import (
+	"embed"
+	"math/rand"
+	"path"
+	"strings"
+
+	"github.com/liuzl/gearley"
+)
+
+//go:embed testdata/*.bnf
+var corpus embed.FS
+
+// MaxLength is the length, in runes, of the longest input: the inputs of the
+// ambiguous grammars have too many trees to build beyond it.
+const MaxLength = 10
+
+// Grammars returns the BNF of the grammars of the corpus, by name.
+func Grammars() map[string]string {
+	files, err := corpus.ReadDir("testdata")
+	if err != nil {
+		panic(err)
+	}
+	grammars := map[string]string{}
+	for _, f := range files {
+		data, err := corpus.ReadFile(path.Join("testdata", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		grammars[strings.TrimSuffix(f.Name(), ".bnf")] = string(data)
+	}
+	return grammars
+}
+
+// Inputs returns the inputs to try on g, of at most MaxLength runes: the
+// sentences of a generation covering its rules, and near misses.
+func Inputs(g *gearley.Grammar) ([]string, error) {
+	sentences, err := gearley.Generate(g, 100, gearley.WithMaxDepth(8),
+		gearley.WithRand(rand.New(rand.NewSource(1))), gearley.WithCoverage())
+	if err != nil {
+		return nil, err
+	}
+	nearMisses, err := gearley.Generate(g, 100, gearley.WithMaxDepth(8),
+		gearley.WithRand(rand.New(rand.NewSource(2))), gearley.WithMutations(1))
+	if err != nil {
+		return nil, err
+	}
+	inputs := []string{""}
+	for _, input := range append(sentences, nearMisses...) {
+		if len([]rune(input)) <= MaxLength {
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs, nil
+}
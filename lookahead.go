@@ -0,0 +1,167 @@
+package gearley
+
+import "reflect"
+
+// The predictor only adds the rules that may start with the token after the
+// set, their lookahead, or derive the empty string: the others could never
+// scan it, nor complete in the set, and would only swell the chart.
+//
+// The rules a non terminal may start with are known from its left corners:
+// B is a left corner of A when A -> α B β with α deriving the empty string,
+// and the left corner relation, closed by transitivity, tells the non
+// terminals a symbol may start with and, through their rules, the terminals.
+
+// bitset is a set of small integers.
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) add(i int) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+// union adds the elements of o to b, and tells whether b changed.
+func (b bitset) union(o bitset) bool {
+	changed := false
+	for i := range b {
+		if b[i]|o[i] != b[i] {
+			b[i] |= o[i]
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (b bitset) intersects(o bitset) bool {
+	for i := range b {
+		if b[i]&o[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compileLeftCorners numbers the terminals of g and computes the terminals
+// each rule may start with.
+func (g *Grammar) compileLeftCorners() {
+	byRune := map[rune]int{}
+	bySymbol := map[Symbol]int{}
+	terminalID := func(s Symbol) int {
+		if t, ok := s.(Terminal); ok {
+			if _, ok := byRune[t.value]; !ok {
+				byRune[t.value] = len(g.terminals)
+				g.terminals = append(g.terminals, s)
+			}
+			return byRune[t.value]
+		}
+		// terminals of other packages may not be usable as keys
+		if !reflect.TypeOf(s).Comparable() {
+			g.terminals = append(g.terminals, s)
+			return len(g.terminals) - 1
+		}
+		if _, ok := bySymbol[s]; !ok {
+			bySymbol[s] = len(g.terminals)
+			g.terminals = append(g.terminals, s)
+		}
+		return bySymbol[s]
+	}
+	// the left corners of each rule, in order: the ids of non terminals, and
+	// of terminals as -1-id
+	starts := make([][]int, len(g.rules))
+	for i, r := range g.rules {
+		for _, s := range r.right {
+			n, ok := s.(NonTerminal)
+			if !ok {
+				starts[i] = append(starts[i], -1-terminalID(s))
+				break
+			}
+			starts[i] = append(starts[i], int(g.symbolIDs[n]))
+			if !g.nullable[n] {
+				break
+			}
+		}
+	}
+
+	// corners[A] are the left corners of A, A included, and first[A] the
+	// terminals starting its rules directly
+	symbols := len(g.rulesByID)
+	corners := make([]bitset, symbols)
+	first := make([]bitset, symbols)
+	for id := range corners {
+		corners[id] = newBitset(symbols)
+		corners[id].add(id)
+		first[id] = newBitset(len(g.terminals))
+	}
+	for i, r := range g.rules {
+		left := g.symbolIDs[r.left]
+		for _, id := range starts[i] {
+			if id >= 0 {
+				corners[left].add(id)
+			} else {
+				first[left].add(-1 - id)
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for a := range corners {
+			for b := 0; b < symbols; b++ {
+				if b != a && corners[a].has(b) && corners[a].union(corners[b]) {
+					changed = true
+				}
+			}
+		}
+	}
+	for a := range first {
+		for b := 0; b < symbols; b++ {
+			if b != a && corners[a].has(b) {
+				first[a].union(first[b])
+			}
+		}
+	}
+
+	g.ruleFirst = make([]bitset, len(g.rules))
+	g.ruleNullable = make([]bool, len(g.rules))
+	for i, r := range g.rules {
+		g.ruleFirst[i] = newBitset(len(g.terminals))
+		for _, id := range starts[i] {
+			if id >= 0 {
+				g.ruleFirst[i].union(first[id])
+			} else {
+				g.ruleFirst[i].add(-1 - id)
+			}
+		}
+		g.ruleNullable[i] = true
+		for _, s := range r.right {
+			if n, ok := s.(NonTerminal); !ok || !g.nullable[n] {
+				g.ruleNullable[i] = false
+			}
+		}
+	}
+}
+
+// lookahead returns the terminals matching the token at pos of in, none at
+// the end of the input.
+func (g *Grammar) lookahead(in input, pos int) bitset {
+	la := newBitset(len(g.terminals))
+	if pos < in.length() {
+		for i, t := range g.terminals {
+			if in.match(t, pos) {
+				la.add(i)
+			}
+		}
+	}
+	return la
+}
+
+// predicts reports whether the predictor adds the rule number r given the
+// lookahead la.
+func (g *Grammar) predicts(r int32, la bitset) bool {
+	return g.ruleNullable[r] || g.ruleFirst[r].intersects(la)
+}
@@ -0,0 +1,62 @@
+package gearley
+
+import (
+	"context"
+
+	"github.com/liuzl/gearley/trace"
+)
+
+// Option tunes a parse.
+type Option func(*config)
+
+type config struct {
+	ctx            context.Context
+	tracer         trace.Tracer
+	maxItemsPerSet int
+	maxChartSize   int
+	maxTrees       int
+	// seeds are the ids of the non terminals predicted in every set, for
+	// constituents to start anywhere
+	seeds []int32
+	// allMatches makes FindAll report every match
+	allMatches bool
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{ctx: context.Background(), tracer: trace.Nop{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithTracer reports every step of the parse to t.
+func WithTracer(t trace.Tracer) Option {
+	return func(cfg *config) {
+		cfg.tracer = t
+	}
+}
+
+// WithMaxItemsPerSet stops the parse with a *LimitError when a state set
+// grows beyond n items.
+func WithMaxItemsPerSet(n int) Option {
+	return func(cfg *config) {
+		cfg.maxItemsPerSet = n
+	}
+}
+
+// WithMaxChartSize stops the parse with a *LimitError when the state sets
+// hold more than n items in total.
+func WithMaxChartSize(n int) Option {
+	return func(cfg *config) {
+		cfg.maxChartSize = n
+	}
+}
+
+// WithAllMatches makes FindAll report every span matching the start symbol,
+// the empty ones and the ones overlapping others included.
+func WithAllMatches() Option {
+	return func(cfg *config) {
+		cfg.allMatches = true
+	}
+}
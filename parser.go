@@ -0,0 +1,40 @@
+package gearley
+
+// Parser is a parsing algorithm working on the grammars of the package, so
+// that algorithms can be compared on the same grammars.
+type Parser interface {
+	// Recognize returns nil if input is a sentence of the grammar, and an
+	// error telling why not otherwise.
+	Recognize(input string) error
+	// Parse returns the forest of the trees of input, or an error when input
+	// is not a sentence of the grammar.
+	Parse(input string) (*Forest, error)
+}
+
+// earleyParser is the Parser of the Earley algorithm of the package.
+type earleyParser struct {
+	g    *Grammar
+	opts []Option
+}
+
+// NewEarleyParser returns the Parser running the Earley algorithm of the
+// package on g, with the options opts.
+func NewEarleyParser(g *Grammar, opts ...Option) Parser {
+	return &earleyParser{g: g, opts: opts}
+}
+
+func (p *earleyParser) Recognize(input string) error {
+	return p.g.Parse(input, p.opts...)
+}
+
+func (p *earleyParser) Parse(input string) (*Forest, error) {
+	runes := stringToRunes(input)
+	st, err := p.g.buildState(runeInput(runes), newConfig(p.opts))
+	if err != nil {
+		return nil, err
+	}
+	if !p.g.accepts(st) {
+		return nil, newParseError(p.g, st, runeInput(runes))
+	}
+	return p.g.forest(st, runes), nil
+}
@@ -0,0 +1,132 @@
+package gearley
+
+import "sort"
+
+// Constituent is a non terminal deriving the runes Start to End of an input.
+type Constituent struct {
+	Symbol     NonTerminal
+	Start, End int
+}
+
+// Constituents returns the constituents of the chart of input: the non
+// terminals of its completed items, with their spans, ordered by start, end,
+// and symbol in order of appearance in the grammar. A rejected input has the
+// constituents of its prefix up to the error, so Constituents only fails when
+// a limit of opts stops the parse.
+func (g *Grammar) Constituents(input string, opts ...Option) ([]Constituent, error) {
+	st, err := g.buildState(runeInput(stringToRunes(input)), newConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+	return g.constituents(st), nil
+}
+
+// Chunks returns the fewest constituents covering as much of input as
+// possible, in order: the value left in an input that is not a sentence,
+// such as the phrases recognised in noisy text. The runes between two chunks
+// are the ones no constituent covers.
+//
+// Every non terminal of the grammar may start anywhere in the chart of
+// Chunks, not only where a sentence could have it. A chunk spanning the same
+// runes as others is the one of the symbol appearing first in the grammar: a
+// sentence is a single chunk of the start symbol.
+func (g *Grammar) Chunks(input string, opts ...Option) ([]Constituent, error) {
+	runes := stringToRunes(input)
+	cfg := newConfig(opts)
+	for id := range g.rulesByID {
+		cfg.seeds = append(cfg.seeds, int32(id))
+	}
+	st, err := g.buildState(runeInput(runes), cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cover(g.constituents(st), len(runes)), nil
+}
+
+// constituents returns the completed items of st, without duplicates, in the
+// order of Constituents.
+func (g *Grammar) constituents(st *state) []Constituent {
+	type key struct {
+		symbol     int32
+		start, end int
+	}
+	seen := map[key]bool{}
+	keys := []key{}
+	for end := range *st {
+		for _, item := range st.getAt(end).items {
+			if !g.isCompleted(item) {
+				continue
+			}
+			k := key{g.dotted(item).left, int(item.index), end}
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		if a.end != b.end {
+			return a.end < b.end
+		}
+		return a.symbol < b.symbol
+	})
+	constituents := make([]Constituent, len(keys))
+	for i, k := range keys {
+		constituents[i] = Constituent{Symbol: g.rules[g.rulesByID[k.symbol][0]].left, Start: k.start, End: k.end}
+	}
+	return constituents
+}
+
+// cover returns the fewest of constituents, ordered as Constituents orders
+// them, covering as many of the n runes of the input as possible.
+func cover(constituents []Constituent, n int) []Constituent {
+	// best[i] covers the runes before i: it leaves gaps runes out, with
+	// chunks constituents, the last being last
+	type path struct {
+		gaps, chunks int
+		last         int
+		prev         int
+	}
+	better := func(a, b path) bool {
+		return a.gaps < b.gaps || a.gaps == b.gaps && a.chunks < b.chunks
+	}
+	best := make([]path, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = path{gaps: n + 1}
+	}
+	best[0] = path{last: -1, prev: -1}
+	// the constituents are ordered by start: relax the positions in order
+	next := 0
+	for i := 0; i <= n; i++ {
+		if i < n {
+			skip := path{gaps: best[i].gaps + 1, chunks: best[i].chunks, last: -1, prev: i}
+			if better(skip, best[i+1]) {
+				best[i+1] = skip
+			}
+		}
+		for ; next < len(constituents) && constituents[next].Start == i; next++ {
+			c := constituents[next]
+			if c.End == c.Start {
+				continue
+			}
+			p := path{gaps: best[i].gaps, chunks: best[i].chunks + 1, last: next, prev: i}
+			if better(p, best[c.End]) {
+				best[c.End] = p
+			}
+		}
+	}
+	chunks := []Constituent{}
+	for i := n; i > 0; i = best[i].prev {
+		if best[i].last >= 0 {
+			chunks = append(chunks, constituents[best[i].last])
+		}
+	}
+	for i, j := 0, len(chunks)-1; i < j; i, j = i+1, j-1 {
+		chunks[i], chunks[j] = chunks[j], chunks[i]
+	}
+	return chunks
+}
@@ -5,16 +5,17 @@ import (
 	"strings"
 )
 
-type rule struct {
-	left  nonTerminal
-	right []symbol
+// Rule is a production of a grammar: left -> right.
+type Rule struct {
+	left  NonTerminal
+	right []Symbol
 }
 
-func (r *rule) length() int {
+func (r *Rule) length() int {
 	return len(r.right)
 }
 
-func (r *rule) String() string {
+func (r *Rule) String() string {
 	rightStrings := make([]string, len(r.right))
 	for i, s := range r.right {
 		rightStrings[i] = s.String()
@@ -22,6 +23,16 @@ func (r *rule) String() string {
 	return fmt.Sprintf("%v -> %v", r.left.String(), strings.Join(rightStrings, " "))
 }
 
-func Rule(t nonTerminal, symbols ...symbol) *rule {
-	return &rule{left: t, right: symbols}
+func NewRule(t NonTerminal, symbols ...Symbol) *Rule {
+	return &Rule{left: t, right: append([]Symbol(nil), symbols...)}
+}
+
+// Left returns the non terminal the rule rewrites.
+func (r *Rule) Left() NonTerminal {
+	return r.left
+}
+
+// Right returns the symbols the rule rewrites its left side to.
+func (r *Rule) Right() []Symbol {
+	return append([]Symbol(nil), r.right...)
 }
@@ -0,0 +1,118 @@
+package gearley
+
+import (
+	"context"
+
+	"github.com/liuzl/gearley/semiring"
+)
+
+// Score parses input and folds every derivation of the start symbol into a
+// single value of the semiring sr: with semiring.Boolean it tells whether
+// input is recognised, with semiring.Counting how many trees it has, with
+// semiring.Viterbi the probability of the best one, and so on.
+// weight is the value of one application of a rule; terminals weigh sr.One().
+// An input that is not a sentence scores sr.Zero(); a parse stopped by one of
+// the limits of opts scores sr.Zero() too, along with its *LimitError.
+func Score[T any](g *Grammar, input string, sr semiring.Semiring[T], weight func(*Rule) T, opts ...Option) (T, error) {
+	return ScoreContext(context.Background(), g, input, sr, weight, opts...)
+}
+
+// ScoreContext is like Score, but gives up with a *LimitError wrapping the
+// error of ctx as soon as ctx is done.
+func ScoreContext[T any](ctx context.Context, g *Grammar, input string, sr semiring.Semiring[T],
+	weight func(*Rule) T, opts ...Option) (T, error) {
+	runes := stringToRunes(input)
+	cfg := newConfig(opts)
+	cfg.ctx = ctx
+	st, err := g.buildState(runeInput(runes), cfg)
+	if err != nil {
+		return sr.Zero(), err
+	}
+	sc := &scorer[T]{
+		g:      g,
+		st:     st,
+		runes:  runes,
+		sr:     sr,
+		weight: weight,
+		memo:   map[scoreKey]T{},
+		active: map[scoreKey]bool{},
+	}
+	total := sr.Zero()
+	last := len(runes)
+	for _, item := range sc.st.getAt(last).items {
+		if g.isCompletedStart(item) {
+			total = sr.Plus(total, sc.complete(item, last))
+		}
+	}
+	return total, nil
+}
+
+// scoreKey identifies an item of the set S(pos).
+type scoreKey struct {
+	item earleyItem
+	pos  int
+}
+
+// scorer walks the derivations recorded in a chart, from the items of the
+// last set back to the first one.
+type scorer[T any] struct {
+	g      *Grammar
+	st     *state
+	runes  []rune
+	sr     semiring.Semiring[T]
+	weight func(*Rule) T
+	memo   map[scoreKey]T
+	// active holds the items being scored, to cut cyclic derivations
+	active map[scoreKey]bool
+}
+
+// complete is the value of the completed item in S(pos): its rule applied to
+// every derivation of its right side.
+func (sc *scorer[T]) complete(item earleyItem, pos int) T {
+	return sc.sr.Times(sc.weight(sc.g.ruleOf(item)), sc.inside(item, pos))
+}
+
+// inside is the value of the derivations of the symbols of item before the
+// dot, spanning the input from item.index to pos.
+func (sc *scorer[T]) inside(item earleyItem, pos int) T {
+	key := scoreKey{item: item, pos: pos}
+	if v, ok := sc.memo[key]; ok {
+		return v
+	}
+	if sc.active[key] {
+		return sc.sr.Zero()
+	}
+	sc.active[key] = true
+	defer delete(sc.active, key)
+
+	v := sc.sr.Zero()
+	if item.dot == 0 {
+		if int(item.index) == pos {
+			v = sc.sr.One()
+		}
+		sc.memo[key] = v
+		return v
+	}
+	prev := earleyItem{rule: item.rule, dot: item.dot - 1, index: item.index}
+	switch s := sc.g.getSymbolAt(item, int(item.dot-1)).(type) {
+	case NonTerminal:
+		// the last symbol spans k..pos for some k, pick every completed item
+		// of s in S(pos) whose origin k holds the previous item
+		for _, c := range sc.st.getAt(pos).items {
+			if c.index < item.index || !sc.g.isCompleted(c) || sc.g.ruleOf(c).left != s {
+				continue
+			}
+			if !sc.st.getAt(int(c.index)).hasItem(prev) {
+				continue
+			}
+			v = sc.sr.Plus(v, sc.sr.Times(sc.inside(prev, int(c.index)), sc.complete(c, pos)))
+		}
+	default:
+		if pos > int(item.index) && s.Match(sc.runes[pos-1]) &&
+			sc.st.getAt(pos-1).hasItem(prev) {
+			v = sc.inside(prev, pos-1)
+		}
+	}
+	sc.memo[key] = v
+	return v
+}
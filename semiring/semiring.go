@@ -0,0 +1,83 @@
+// Package semiring provides the algebraic structures the parsers use to fold
+// the derivations stored in a chart into a single value.
+//
+// A chart pass computes, for every item, the semiring sum over all of its
+// derivations of the semiring product of the weights used by each derivation.
+// Picking the semiring picks the question being answered:
+//
+//	Boolean    is the input recognised at all
+//	Counting   how many parse trees are there
+//	Viterbi    what is the probability of the best tree
+//	Inside     what is the total probability of the input
+//	LogInside  the same as Inside, in log space
+package semiring
+
+import "math"
+
+// Semiring is a set T with an additive and a multiplicative monoid where
+// multiplication distributes over addition and Zero annihilates.
+type Semiring[T any] interface {
+	// Zero is the identity of Plus, the value of "no derivation".
+	Zero() T
+	// One is the identity of Times, the value of the empty derivation.
+	One() T
+	// Plus combines alternative derivations.
+	Plus(a, b T) T
+	// Times combines consecutive parts of one derivation.
+	Times(a, b T) T
+}
+
+// Boolean is the recognition semiring ({false, true}, or, and).
+type Boolean struct{}
+
+func (Boolean) Zero() bool           { return false }
+func (Boolean) One() bool            { return true }
+func (Boolean) Plus(a, b bool) bool  { return a || b }
+func (Boolean) Times(a, b bool) bool { return a && b }
+
+// Counting is the derivation counting semiring (N, +, *).
+// Counts wrap around on overflow, like any other uint64 arithmetic.
+type Counting struct{}
+
+func (Counting) Zero() uint64             { return 0 }
+func (Counting) One() uint64              { return 1 }
+func (Counting) Plus(a, b uint64) uint64  { return a + b }
+func (Counting) Times(a, b uint64) uint64 { return a * b }
+
+// Viterbi is the max-product semiring over probabilities ([0, 1], max, *).
+type Viterbi struct{}
+
+func (Viterbi) Zero() float64              { return 0 }
+func (Viterbi) One() float64               { return 1 }
+func (Viterbi) Plus(a, b float64) float64  { return math.Max(a, b) }
+func (Viterbi) Times(a, b float64) float64 { return a * b }
+
+// Inside is the sum-product semiring over probabilities ([0, 1], +, *).
+type Inside struct{}
+
+func (Inside) Zero() float64              { return 0 }
+func (Inside) One() float64               { return 1 }
+func (Inside) Plus(a, b float64) float64  { return a + b }
+func (Inside) Times(a, b float64) float64 { return a * b }
+
+// LogInside is the log-sum-exp semiring over log probabilities
+// ([-Inf, 0], logaddexp, +). It computes the same values as Inside without
+// underflowing on long inputs.
+type LogInside struct{}
+
+func (LogInside) Zero() float64              { return math.Inf(-1) }
+func (LogInside) One() float64               { return 0 }
+func (LogInside) Times(a, b float64) float64 { return a + b }
+
+func (LogInside) Plus(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
@@ -0,0 +1,22 @@
+package semiring
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLogInside(t *testing.T) {
+	var s LogInside
+	for _, c := range [][2]float64{{0.5, 0.25}, {1e-300, 1e-300}, {0.1, 0}} {
+		got := s.Plus(math.Log(c[0]), math.Log(c[1]))
+		if want := math.Log(c[0] + c[1]); math.Abs(got-want) > 1e-12 {
+			t.Errorf("Plus(log %v, log %v) = %v, want %v", c[0], c[1], got, want)
+		}
+	}
+	if got := s.Plus(s.Zero(), s.Zero()); !math.IsInf(got, -1) {
+		t.Errorf("Plus(Zero, Zero) = %v", got)
+	}
+	if got := s.Times(math.Log(0.5), s.One()); got != math.Log(0.5) {
+		t.Errorf("Times(log 0.5, One) = %v", got)
+	}
+}
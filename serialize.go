@@ -0,0 +1,107 @@
+package gearley
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/liuzl/gearley/internal/bnf"
+)
+
+// The JSON form of a grammar lists its rules in order, so the start symbol
+// and the order of alternatives survive a round trip:
+//
+//	{"start": "T", "rules": [
+//	  {"left": "T", "right": [{"kind": "rune", "value": "a"}, {"kind": "rune", "value": "b"}]},
+//	  {"left": "T", "right": [{"kind": "rune", "value": "a"}, {"kind": "nonterminal", "name": "T"}, ...]}
+//	]}
+//
+// Matchers are stored by name ({"kind": "func", "name": "digit"}) and have to
+// be handed back to UnmarshalGrammar.
+
+type jsonGrammar struct {
+	Start string     `json:"start"`
+	Rules []jsonRule `json:"rules"`
+}
+
+type jsonRule struct {
+	Left  string       `json:"left"`
+	Right []jsonSymbol `json:"right"`
+}
+
+type jsonSymbol struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+const (
+	kindRune        = "rune"
+	kindNonTerminal = "nonterminal"
+	kindFunc        = "func"
+)
+
+// MarshalJSON encodes the grammar. It fails on terminal kinds defined outside
+// of this package, which have no portable representation.
+func (g *Grammar) MarshalJSON() ([]byte, error) {
+	jg := jsonGrammar{Rules: make([]jsonRule, len(g.rules))}
+	if len(g.rules) > 0 {
+		jg.Start = g.start().name
+	}
+	for i, r := range g.rules {
+		jr := jsonRule{Left: r.left.name, Right: make([]jsonSymbol, len(r.right))}
+		for j, s := range r.right {
+			switch s := s.(type) {
+			case Terminal:
+				jr.Right[j] = jsonSymbol{Kind: kindRune, Value: string(s.value)}
+			case NonTerminal:
+				jr.Right[j] = jsonSymbol{Kind: kindNonTerminal, Name: s.name}
+			case *Matcher:
+				jr.Right[j] = jsonSymbol{Kind: kindFunc, Name: s.name}
+			default:
+				return nil, fmt.Errorf("gearley: cannot encode symbol %v of type %T", s, s)
+			}
+		}
+		jg.Rules[i] = jr
+	}
+	return json.Marshal(jg)
+}
+
+// UnmarshalGrammar decodes a grammar encoded by MarshalJSON. The matchers
+// the grammar refers to are looked up by name in matchers.
+func UnmarshalGrammar(data []byte, matchers ...*Matcher) (*Grammar, error) {
+	var jg jsonGrammar
+	if err := json.Unmarshal(data, &jg); err != nil {
+		return nil, err
+	}
+	byName := bnf.ByName(matchers, (*Matcher).Name)
+	rules := make([]*Rule, len(jg.Rules))
+	for i, jr := range jg.Rules {
+		right := make([]Symbol, len(jr.Right))
+		for j, js := range jr.Right {
+			switch js.Kind {
+			case kindRune:
+				runes := []rune(js.Value)
+				if len(runes) != 1 {
+					return nil, fmt.Errorf("gearley: rule %d: rune terminal %q is not one rune", i, js.Value)
+				}
+				right[j] = NewTerminal(runes[0])
+			case kindNonTerminal:
+				right[j] = NewNonTerminal(js.Name)
+			case kindFunc:
+				m, ok := byName[js.Name]
+				if !ok {
+					return nil, fmt.Errorf("gearley: rule %d: unknown matcher %q", i, js.Name)
+				}
+				right[j] = m
+			default:
+				return nil, fmt.Errorf("gearley: rule %d: unknown symbol kind %q", i, js.Kind)
+			}
+		}
+		rules[i] = NewRule(NewNonTerminal(jr.Left), right...)
+	}
+	g := NewGrammar(rules...)
+	if len(rules) > 0 && jg.Start != g.start().name {
+		return nil, fmt.Errorf("gearley: start symbol %q is not the left side of the first rule", jg.Start)
+	}
+	return g, nil
+}
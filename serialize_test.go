@@ -0,0 +1,121 @@
+package gearley
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"unicode"
+)
+
+func exprGrammar() *Grammar {
+	E := NewNonTerminal("E")
+	N := NewNonTerminal("N")
+	W := NewNonTerminal("W")
+	digit := TerminalFunc("digit", unicode.IsDigit)
+	return NewGrammar(
+		NewRule(E, E, NewTerminal('+'), E), // E -> E '+' E
+		NewRule(N, digit),                  // N -> [digit]
+		NewRule(E, W, N, W),                // E -> W N W
+		NewRule(W),                         // W ->
+		NewRule(W, NewTerminal(' ')),       // W -> ' '
+		NewRule(N, N, digit),               // N -> N [digit]
+	)
+}
+
+func Test_Grammar_JSON(t *testing.T) {
+	g := exprGrammar()
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2, err := UnmarshalGrammar(data, TerminalFunc("digit", unicode.IsDigit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ruleStrings(g), ruleStrings(g2)) || g2.Start() != g.Start() {
+		t.Errorf("round trip changed the grammar:\n%v\n%v", ruleStrings(g), ruleStrings(g2))
+	}
+	if err := g2.Parse("1 + 23+4"); err != nil {
+		t.Errorf("Parse: %v", err)
+	}
+	if _, err := UnmarshalGrammar(data); err == nil {
+		t.Errorf("decoded without the digit matcher")
+	}
+	if _, err := json.Marshal(NewGrammar(NewRule(T, digit{}))); err == nil {
+		t.Errorf("encoded a foreign terminal kind")
+	}
+}
+
+func Test_Grammar_BNF(t *testing.T) {
+	g := exprGrammar()
+	want := `<E> ::= <E> "+" <E>
+      | <W> <N> <W>
+<N> ::= [digit]
+      | <N> [digit]
+<W> ::= ""
+      | " "
+`
+	if got := g.BNF(); got != want {
+		t.Errorf("BNF() =\n%s\nwant\n%s", got, want)
+	}
+	g2, err := ParseBNF(want, TerminalFunc("digit", unicode.IsDigit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := g2.BNF(); got != want {
+		t.Errorf("BNF round trip =\n%s\nwant\n%s", got, want)
+	}
+
+	g3, err := ParseBNF(`
+# a comment
+<T> ::= "ab" | "a" <T> "b"  # strings stand for one terminal per rune
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ruleStrings(g3), []string{"T -> 'a' 'b'", "T -> 'a' T 'b'"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rules = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"", `"a"`, `<T> ::= [x]`, `<T> ::= "a`, `<T ::= "a"`, `<T> ::= ::=`} {
+		if _, err := ParseBNF(bad); err == nil {
+			t.Errorf("ParseBNF(%q) succeeded", bad)
+		}
+	}
+}
+
+func Test_Grammar_BNF_escapes(t *testing.T) {
+	A := NewNonTerminal("a>b")
+	B := NewNonTerminal(`x y"\`)
+	C := NewNonTerminal("two\nlines")
+	odd := TerminalFunc("]odd\\", unicode.IsDigit)
+	g := NewGrammar(
+		NewRule(A, B, NewTerminal('>'), C),
+		NewRule(B, odd, NewTerminal('"')),
+		NewRule(C, NewTerminal('\\'), NewTerminal('\n')),
+	)
+	want := `<a\>b> ::= <x y"\\> ">" <two\nlines>
+<x y"\\> ::= [\]odd\\] "\""
+<two\nlines> ::= "\\" "\n"
+`
+	if got := g.BNF(); got != want {
+		t.Errorf("BNF() =\n%s\nwant\n%s", got, want)
+	}
+	g2, err := ParseBNF(want, odd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ruleStrings(g2), ruleStrings(g)) || g2.Start() != A {
+		t.Errorf("round trip changed the grammar:\n%v\n%v", ruleStrings(g), ruleStrings(g2))
+	}
+	if err := g2.Parse("1\">\\\n"); err != nil {
+		t.Errorf("Parse: %v", err)
+	}
+}
+
+func ruleStrings(g *Grammar) []string {
+	s := make([]string, len(g.Rules()))
+	for i, r := range g.Rules() {
+		s[i] = r.String()
+	}
+	return s
+}
@@ -1,43 +1,91 @@
 package gearley
 
-import "fmt"
+import "reflect"
 
+// stateSet is the arena of the items of one set: items are stored in place,
+// in order of arrival.
 type stateSet struct {
-	items   []*earleyItem
-	itemSet map[earleyItem]bool
-}
-
-func newStateSet() *stateSet {
-	return &stateSet{items: []*earleyItem{}, itemSet: make(map[earleyItem]bool)}
-}
-
-func (s *stateSet) String() string {
-	return fmt.Sprint(s.items)
+	items   []earleyItem
+	itemSet map[earleyItem]struct{}
+	// waiting maps the id of a non terminal to the positions in items of the
+	// items waiting for it
+	waiting map[int32][]int32
 }
 
 func (s *stateSet) length() int {
 	return len(s.items)
 }
 
-func (s *stateSet) putItem(item *earleyItem) {
-	if _, ok := s.itemSet[*item]; ok {
-		return
+// putItem adds item to the set, unless it holds it already, and tells
+// which one it was.
+func (s *stateSet) putItem(g *Grammar, item earleyItem) bool {
+	if s.itemSet == nil {
+		s.itemSet = map[earleyItem]struct{}{}
+		s.waiting = map[int32][]int32{}
+	}
+	if _, ok := s.itemSet[item]; ok {
+		return false
+	}
+	s.itemSet[item] = struct{}{}
+	if id := g.dotted(item).nextID; id >= 0 {
+		s.waiting[id] = append(s.waiting[id], int32(len(s.items)))
 	}
-	s.itemSet[*item] = true
 	s.items = append(s.items, item)
+	return true
+}
+
+func (s *stateSet) hasItem(item earleyItem) bool {
+	_, ok := s.itemSet[item]
+	return ok
 }
 
-func (s *stateSet) findItemsToComplete(t nonTerminal) []*earleyItem {
-	candidates := []*earleyItem{}
-	for _, item := range s.items {
-		switch c := item.rule.right[item.dot].(type) {
-		case nonTerminal:
-			if c.name == t.name {
-				candidates = append(candidates, item)
+// findItemsToComplete returns the positions of the items waiting for the non
+// terminal of id t.
+func (s *stateSet) findItemsToComplete(t int32) []int32 {
+	return s.waiting[t]
+}
+
+// expectedTerminals returns the terminals the items of the set wait for,
+// without duplicates, including the items the predictor left out for not
+// starting with the lookahead. Terminals are told apart by value, not by
+// name: two matchers of the same name are expected each.
+func (s *stateSet) expectedTerminals(g *Grammar) []Symbol {
+	expected := []Symbol{}
+	seen := map[interface{}]bool{}
+	// the items of the set, and the ones the predictor left out
+	closure := map[earleyItem]bool{}
+	queue := append([]earleyItem(nil), s.items...)
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		// the items left out have the origin of the set, which does not
+		// matter here: tell them apart by rule and dot only
+		key := earleyItem{rule: item.rule, dot: item.dot}
+		if closure[key] {
+			continue
+		}
+		closure[key] = true
+		d := g.dotted(item)
+		switch {
+		case d.next == nil:
+		case d.nextID >= 0:
+			for _, r := range g.rulesByID[d.nextID] {
+				queue = append(queue, earleyItem{rule: r})
+			}
+			if g.nullableIDs[d.nextID] {
+				queue = append(queue, item.advance())
 			}
 		default:
-			continue
+			var key interface{} = d.next
+			// terminals of other packages may not be usable as keys
+			if !reflect.TypeOf(d.next).Comparable() {
+				key = d.next.String()
+			}
+			if !seen[key] {
+				seen[key] = true
+				expected = append(expected, d.next)
+			}
 		}
 	}
-	return candidates
+	return expected
 }
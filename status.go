@@ -0,0 +1,45 @@
+package gearley
+
+// Status tells how far an input is from being a sentence, for interactive
+// inputs to tell whether to wait for more.
+type Status int
+
+const (
+	// Invalid is an input that no suffix turns into a sentence.
+	Invalid Status = iota
+	// Incomplete is the beginning of a sentence, awaiting more.
+	Incomplete
+	// Complete is a sentence, which may still be the beginning of longer
+	// ones.
+	Complete
+)
+
+func (s Status) String() string {
+	switch s {
+	case Invalid:
+		return "invalid"
+	case Incomplete:
+		return "incomplete"
+	case Complete:
+		return "complete"
+	}
+	return "unknown"
+}
+
+// Status returns the status of input, from the last set of its chart: input
+// is Complete when it holds a completed start item, Incomplete when it holds
+// other items, and Invalid when it is empty. It only fails when a limit of
+// opts stops the parse.
+func (g *Grammar) Status(input string, opts ...Option) (Status, error) {
+	st, err := g.buildState(runeInput(stringToRunes(input)), newConfig(opts))
+	if err != nil {
+		return Invalid, err
+	}
+	switch {
+	case g.accepts(st):
+		return Complete, nil
+	case st.getAt(len(*st)-1).length() > 0:
+		return Incomplete, nil
+	}
+	return Invalid, nil
+}
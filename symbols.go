@@ -2,50 +2,98 @@ package gearley
 
 import "fmt"
 
-type symbol interface {
-	// isTerminal indicates if the symbol is Terminal Symbol or Non Terminal Symbol.
-	isTerminal() bool
+// Symbol is a symbol of a rule.
+// Non terminal symbols are always NonTerminal values; every other
+// implementation is a terminal, which packages may add to match runes
+// in their own way.
+type Symbol interface {
+	// IsTerminal indicates if the Symbol is Terminal Symbol or Non Terminal Symbol.
+	IsTerminal() bool
 	String() string
-	isMatchingTerminal(rune) bool
-	// s and input are slices of the full state set and the full input.
+	// Match reports whether the terminal accepts the input rune r.
+	// Non terminals match nothing.
+	Match(r rune) bool
 }
 
-type terminal struct {
+// Terminal is a terminal matching a single rune.
+type Terminal struct {
 	value rune
 }
 
-func Terminal(r rune) terminal {
-	return terminal{value: r}
+func NewTerminal(r rune) Terminal {
+	return Terminal{value: r}
 }
 
-func (t terminal) isTerminal() bool {
+// Rune returns the rune matched by the terminal.
+func (t Terminal) Rune() rune {
+	return t.value
+}
+
+func (t Terminal) IsTerminal() bool {
 	return true
 }
 
-func (t terminal) String() string {
+func (t Terminal) String() string {
 	return fmt.Sprintf("'%c'", t.value)
 }
 
-func (t terminal) isMatchingTerminal(r rune) bool {
+func (t Terminal) Match(r rune) bool {
 	return r == t.value
 }
 
-type nonTerminal struct {
+// NonTerminal is a non terminal symbol, identified by its name.
+type NonTerminal struct {
 	name string
 }
 
-func NonTerminal(name string) nonTerminal {
-	return nonTerminal{name: name}
+func NewNonTerminal(name string) NonTerminal {
+	return NonTerminal{name: name}
+}
+
+// Name returns the name of the non terminal.
+func (n NonTerminal) Name() string {
+	return n.name
 }
 
-func (n nonTerminal) isTerminal() bool {
+func (n NonTerminal) IsTerminal() bool {
 	return false
 }
 
-func (n nonTerminal) String() string {
+func (n NonTerminal) String() string {
 	return n.name
 }
 
-func (n nonTerminal) isMatchingTerminal(r rune) bool {
+func (n NonTerminal) Match(r rune) bool {
 	return false
 }
+
+// Matcher is a terminal matching every rune its predicate accepts,
+// such as "any letter" or "anything but a quote".
+type Matcher struct {
+	name  string
+	match func(rune) bool
+}
+
+// TerminalFunc returns a terminal matching the runes accepted by match.
+// name stands for the terminal in grammar listings and error messages.
+// match may be nil for the terminals of Parse, which do not match runes.
+func TerminalFunc(name string, match func(rune) bool) *Matcher {
+	return &Matcher{name: name, match: match}
+}
+
+// Name returns the name of the terminal.
+func (m *Matcher) Name() string {
+	return m.name
+}
+
+func (m *Matcher) IsTerminal() bool {
+	return true
+}
+
+func (m *Matcher) String() string {
+	return "[" + m.name + "]"
+}
+
+func (m *Matcher) Match(r rune) bool {
+	return m.match != nil && m.match(r)
+}
@@ -0,0 +1,43 @@
+package gearley
+
+// input is what the parser reads: a sequence of tokens, each matched by some
+// of the terminals. Strings are read rune by rune.
+type input interface {
+	length() int
+	// match reports whether the terminal s matches the i-th token.
+	match(s Symbol, i int) bool
+	// token returns the i-th token.
+	token(i int) interface{}
+}
+
+type runeInput []rune
+
+func (in runeInput) length() int                { return len(in) }
+func (in runeInput) match(s Symbol, i int) bool { return s.Match(in[i]) }
+func (in runeInput) token(i int) interface{}    { return in[i] }
+
+// tokenInput is a sequence of tokens of any type, and the way terminals
+// match them.
+type tokenInput[T any] struct {
+	tokens  []T
+	matches func(Symbol, T) bool
+}
+
+func (in tokenInput[T]) length() int                { return len(in.tokens) }
+func (in tokenInput[T]) match(s Symbol, i int) bool { return in.matches(s, in.tokens[i]) }
+func (in tokenInput[T]) token(i int) interface{}    { return in.tokens[i] }
+
+// Parse reports whether tokens is a sentence of g, like Grammar.Parse does
+// for the runes of a string: match tells whether a terminal of g matches a
+// token, and is all the parser knows of tokens. It is never called with non
+// terminals.
+//
+// The terminals may be of any kind: matchers named after the tokens they
+// match and a match comparing names, say, make grammars of lexer tokens or
+// part of speech tags readable in BNF.
+//
+// The positions of errors count tokens, and the unexpected token is in the
+// Token field of the *ParseError.
+func Parse[T any](g *Grammar, tokens []T, match func(Symbol, T) bool, opts ...Option) error {
+	return g.parse(tokenInput[T]{tokens: tokens, matches: match}, newConfig(opts))
+}
@@ -0,0 +1,121 @@
+// Package trace defines the hooks the parsers call at each step of a parse,
+// and a few ready made implementations.
+//
+// Parsers call a Tracer from the goroutine that parses; the tracers of this
+// package may be shared by parses running in parallel.
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Tracer receives the steps of a parse. Items are the parser's own chart
+// items, pos is the index of the state set or column they belong to.
+type Tracer interface {
+	// OnPredict is called for an item added by prediction.
+	OnPredict(item fmt.Stringer, pos int)
+	// OnScan is called for an item added by scanning the token before pos.
+	OnScan(item fmt.Stringer, pos int)
+	// OnComplete is called for an item added by completion.
+	OnComplete(item fmt.Stringer, pos int)
+	// OnSetDone is called once the set pos holds all its items.
+	OnSetDone(pos int, size int)
+	// OnTreeBuild is called for each completed item a tree is built from.
+	OnTreeBuild(item fmt.Stringer, pos int)
+}
+
+// Nop is the Tracer that ignores everything.
+type Nop struct{}
+
+func (Nop) OnPredict(fmt.Stringer, int)   {}
+func (Nop) OnScan(fmt.Stringer, int)      {}
+func (Nop) OnComplete(fmt.Stringer, int)  {}
+func (Nop) OnSetDone(int, int)            {}
+func (Nop) OnTreeBuild(fmt.Stringer, int) {}
+
+// Text writes one human readable line per event:
+//
+//	predict  S(0) T -> ●'a' 'b' (0)
+type Text struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewText returns a Tracer writing text lines to w.
+func NewText(w io.Writer) *Text {
+	return &Text{w: w}
+}
+
+func (t *Text) printf(format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, format, args...)
+}
+
+func (t *Text) OnPredict(item fmt.Stringer, pos int) {
+	t.printf("predict  S(%d) %v\n", pos, item)
+}
+
+func (t *Text) OnScan(item fmt.Stringer, pos int) {
+	t.printf("scan     S(%d) %v\n", pos, item)
+}
+
+func (t *Text) OnComplete(item fmt.Stringer, pos int) {
+	t.printf("complete S(%d) %v\n", pos, item)
+}
+
+func (t *Text) OnSetDone(pos int, size int) {
+	t.printf("done     S(%d) %d items\n", pos, size)
+}
+
+func (t *Text) OnTreeBuild(item fmt.Stringer, pos int) {
+	t.printf("tree     S(%d) %v\n", pos, item)
+}
+
+// Event is the JSON form of a traced step.
+type Event struct {
+	Event string `json:"event"`
+	Pos   int    `json:"pos"`
+	Item  string `json:"item,omitempty"`
+	Size  int    `json:"size,omitempty"`
+}
+
+// JSON records the events as JSON lines, one Event per line.
+type JSON struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSON returns a Tracer writing JSON lines to w.
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{enc: json.NewEncoder(w)}
+}
+
+func (j *JSON) record(e Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.enc.Encode(e)
+}
+
+func (j *JSON) OnPredict(item fmt.Stringer, pos int) {
+	j.record(Event{Event: "predict", Pos: pos, Item: item.String()})
+}
+
+func (j *JSON) OnScan(item fmt.Stringer, pos int) {
+	j.record(Event{Event: "scan", Pos: pos, Item: item.String()})
+}
+
+func (j *JSON) OnComplete(item fmt.Stringer, pos int) {
+	j.record(Event{Event: "complete", Pos: pos, Item: item.String()})
+}
+
+func (j *JSON) OnSetDone(pos int, size int) {
+	j.record(Event{Event: "done", Pos: pos, Size: size})
+}
+
+func (j *JSON) OnTreeBuild(item fmt.Stringer, pos int) {
+	j.record(Event{Event: "tree", Pos: pos, Item: item.String()})
+}
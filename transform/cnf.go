@@ -0,0 +1,237 @@
+package transform
+
+import (
+	"reflect"
+
+	"github.com/liuzl/gearley"
+)
+
+// EliminateEpsilon removes the rules with an empty right side: every rule
+// gets a copy without each combination of the nullable symbols of its right
+// side instead. When the start symbol is nullable, a new start symbol
+// derives it or the empty string, and is the only one to.
+//
+// Symbols left without rules stay behind, for Reduce to remove.
+func EliminateEpsilon(g *gearley.Grammar) (*Result, error) {
+	// the rule making each nullable symbol nullable, the one its empty
+	// trees are built with
+	nullableBy := map[gearley.NonTerminal]*gearley.Rule{}
+	nullable := map[gearley.NonTerminal]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range g.Rules() {
+			if nullable[r.Left()] || !allNullable(r.Right(), nullable) {
+				continue
+			}
+			nullableBy[r.Left()] = r
+			nullable[r.Left()] = true
+			changed = true
+		}
+	}
+	var empty func(n gearley.NonTerminal) part
+	empty = func(n gearley.NonTerminal) part {
+		r := nullableBy[n]
+		parts := []part{}
+		for _, s := range r.Right() {
+			parts = append(parts, empty(s.(gearley.NonTerminal)))
+		}
+		return node(r, parts...)
+	}
+
+	b := newBuilder(g)
+	start := g.Start()
+	if nullable[start] {
+		newStart := b.fresh(start.Name())
+		for _, r := range g.Rules() {
+			if r.Left() == start && len(r.Right()) > 0 {
+				b.add(gearley.NewRule(newStart, start), []part{hole(0)})
+				break
+			}
+		}
+		b.add(gearley.NewRule(newStart), []part{empty(start)})
+	}
+	for _, r := range g.Rules() {
+		right := r.Right()
+		positions := []int{}
+		for i, s := range right {
+			if n, ok := s.(gearley.NonTerminal); ok && nullable[n] {
+				positions = append(positions, i)
+			}
+		}
+		// the bits of dropped are the nullable symbols left out
+		for dropped := 0; dropped < 1<<len(positions); dropped++ {
+			isDropped := map[int]bool{}
+			for bit, i := range positions {
+				if dropped&(1<<bit) != 0 {
+					isDropped[i] = true
+				}
+			}
+			kept := []gearley.Symbol{}
+			parts := []part{}
+			for i, s := range right {
+				if isDropped[i] {
+					parts = append(parts, empty(s.(gearley.NonTerminal)))
+				} else {
+					parts = append(parts, hole(len(kept)))
+					kept = append(kept, s)
+				}
+			}
+			if len(kept) > 0 {
+				b.add(gearley.NewRule(r.Left(), kept...), []part{node(r, parts...)})
+			}
+		}
+	}
+	return b.result(g)
+}
+
+func allNullable(symbols []gearley.Symbol, nullable map[gearley.NonTerminal]bool) bool {
+	for _, s := range symbols {
+		if n, ok := s.(gearley.NonTerminal); !ok || !nullable[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// isUnit reports whether r rewrites a non terminal to a single non terminal.
+func isUnit(r *gearley.Rule) bool {
+	if len(r.Right()) != 1 {
+		return false
+	}
+	_, ok := r.Right()[0].(gearley.NonTerminal)
+	return ok
+}
+
+// EliminateUnit removes the unit rules, the rules A -> B rewriting a non
+// terminal to another one: A gets the other rules of every non terminal B
+// it derives through unit rules instead.
+func EliminateUnit(g *gearley.Grammar) (*Result, error) {
+	ix := index(g)
+	b := newBuilder(g)
+	for _, a := range ix.symbols {
+		// the unit rules from a to each non terminal it reaches through
+		// them, breadth first for the shortest chains
+		chains := map[gearley.NonTerminal][]*gearley.Rule{a: nil}
+		queue := []gearley.NonTerminal{a}
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			for _, r := range ix.rules[n] {
+				if !isUnit(r) {
+					b.add(gearley.NewRule(a, r.Right()...), []part{wrap(chains[n], r)})
+					continue
+				}
+				m := r.Right()[0].(gearley.NonTerminal)
+				if _, ok := chains[m]; !ok {
+					chains[m] = append(append([]*gearley.Rule(nil), chains[n]...), r)
+					queue = append(queue, m)
+				}
+			}
+		}
+	}
+	return b.result(g)
+}
+
+// wrap returns the template of r applied under the chain of unit rules.
+func wrap(chain []*gearley.Rule, r *gearley.Rule) part {
+	p := node(r, holes(0, len(r.Right()))...)
+	for i := len(chain) - 1; i >= 0; i-- {
+		p = node(chain[i], p)
+	}
+	return p
+}
+
+// separateTerminals moves the terminals out of the rules of two symbols or
+// more: each terminal gets a non terminal of its own rewriting to it, named
+// after it. Terminals are told apart by value, not by name: two matchers
+// of the same name get a non terminal each.
+func separateTerminals(g *gearley.Grammar) (*Result, error) {
+	b := newBuilder(g)
+	byTerminal := map[gearley.Symbol]gearley.NonTerminal{}
+	terminals := []*gearley.Rule{}
+	for _, r := range g.Rules() {
+		right := r.Right()
+		if len(right) < 2 {
+			b.add(r, identity(r))
+			continue
+		}
+		for i, s := range right {
+			if !s.IsTerminal() {
+				continue
+			}
+			// terminals of other packages may not be usable as keys
+			keyed := reflect.TypeOf(s).Comparable()
+			n, ok := gearley.NonTerminal{}, false
+			if keyed {
+				n, ok = byTerminal[s]
+			}
+			if !ok {
+				n = gearley.NewNonTerminal(s.String())
+				if b.names[n.Name()] {
+					n = b.fresh(s.String())
+				}
+				b.names[n.Name()] = true
+				if keyed {
+					byTerminal[s] = n
+				}
+				terminals = append(terminals, gearley.NewRule(n, s))
+			}
+			right[i] = n
+		}
+		b.add(gearley.NewRule(r.Left(), right...), identity(r))
+	}
+	for _, r := range terminals {
+		b.add(r, []part{hole(0)})
+	}
+	return b.result(g)
+}
+
+// binarize splits the rules of three symbols or more into rules of two:
+// A -> X Y Z becomes A -> X A_1 and A_1 -> Y Z.
+func binarize(g *gearley.Grammar) (*Result, error) {
+	b := newBuilder(g)
+	for _, r := range g.Rules() {
+		right := r.Right()
+		if len(right) < 3 {
+			b.add(r, identity(r))
+			continue
+		}
+		left := r.Left()
+		rest := b.fresh(r.Left().Name())
+		b.add(gearley.NewRule(left, right[0], rest), []part{node(r, hole(0), hole(1))})
+		for i := 1; i < len(right)-2; i++ {
+			next := b.fresh(r.Left().Name())
+			b.add(gearley.NewRule(rest, right[i], next), holes(0, 2))
+			rest = next
+		}
+		b.add(gearley.NewRule(rest, right[len(right)-2:]...), holes(0, 2))
+	}
+	return b.result(g)
+}
+
+// CNF converts g to Chomsky Normal Form, see IsCNF. It fails when the start
+// symbol derives no string.
+func CNF(g *gearley.Grammar) (*Result, error) {
+	return Apply(g, Reduce, separateTerminals, binarize, EliminateEpsilon, EliminateUnit, Reduce)
+}
+
+// IsCNF reports whether g is in Chomsky Normal Form: every rule rewrites a
+// non terminal to two non terminals or to one terminal, but for a rule
+// rewriting the start symbol to the empty string, in which case the start
+// symbol is on no right side.
+func IsCNF(g *gearley.Grammar) bool {
+	empty, onRight := false, false
+	for _, r := range g.Rules() {
+		right := r.Right()
+		switch {
+		case len(right) == 0 && r.Left() == g.Start():
+			empty = true
+		case len(right) == 1 && right[0].IsTerminal():
+		case len(right) == 2 && !right[0].IsTerminal() && !right[1].IsTerminal():
+			onRight = onRight || right[0] == g.Start() || right[1] == g.Start()
+		default:
+			return false
+		}
+	}
+	return !empty || !onRight
+}
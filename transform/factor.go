@@ -0,0 +1,76 @@
+package transform
+
+import "github.com/liuzl/gearley"
+
+// LeftFactor factors out the prefixes the rules of a non terminal have in
+// common: A -> α β | α γ becomes A -> α A_1 and A_1 -> β | γ, and so on
+// until no two rules of a non terminal start with the same symbol.
+func LeftFactor(g *gearley.Grammar) (*Result, error) {
+	ix := index(g)
+	b := newBuilder(g)
+	for _, n := range ix.symbols {
+		alternatives := []alternative{}
+		for _, r := range ix.rules[n] {
+			alternatives = append(alternatives, alternative{right: r.Right(), rule: r})
+		}
+		b.factor(n, alternatives)
+	}
+	return b.result(g)
+}
+
+// alternative is what is left to derive of the right side of rule.
+type alternative struct {
+	right []gearley.Symbol
+	rule  *gearley.Rule
+}
+
+// factor adds the rules of left deriving the alternatives. The trees of the
+// rules stand for the trees of the rules of the alternatives: without their
+// factored out prefixes, which the rules of the prefixes add back.
+func (b *builder) factor(left gearley.NonTerminal, alternatives []alternative) {
+	// the alternatives grouped by their first symbol, in order
+	groups := [][]alternative{}
+	for _, alt := range alternatives {
+		i := 0
+		for len(alt.right) > 0 && i < len(groups) &&
+			(len(groups[i][0].right) == 0 || groups[i][0].right[0] != alt.right[0]) {
+			i++
+		}
+		if len(alt.right) == 0 || i == len(groups) {
+			groups = append(groups, []alternative{alt})
+		} else {
+			groups[i] = append(groups[i], alt)
+		}
+	}
+	for _, group := range groups {
+		if len(group) == 1 {
+			alt := group[0]
+			b.add(gearley.NewRule(left, alt.right...),
+				[]part{node(alt.rule, holes(0, len(alt.right))...)})
+			continue
+		}
+		prefix := commonPrefix(group)
+		rest := b.fresh(left.Name())
+		right := append(append([]gearley.Symbol(nil), prefix...), rest)
+		b.add(gearley.NewRule(left, right...),
+			[]part{{kind: adoptPart, hole: len(prefix), parts: holes(0, len(prefix))}})
+		suffixes := []alternative{}
+		for _, alt := range group {
+			suffixes = append(suffixes, alternative{right: alt.right[len(prefix):], rule: alt.rule})
+		}
+		b.factor(rest, suffixes)
+	}
+}
+
+// commonPrefix returns the longest prefix of the alternatives.
+func commonPrefix(alternatives []alternative) []gearley.Symbol {
+	prefix := alternatives[0].right
+	for _, alt := range alternatives[1:] {
+		n := 0
+		for n < len(prefix) && n < len(alt.right) && prefix[n] == alt.right[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return prefix
+}
@@ -0,0 +1,331 @@
+// Package transform rewrites grammars into equivalent ones: grammars of the
+// same language, in a form some algorithm needs or parses faster.
+//
+// Every transformation keeps the way back: Result.Restore turns a tree of
+// the transformed grammar into a tree of the original one.
+//
+//	cnf, err := transform.CNF(g)
+//	...
+//	trees, err := cnf.Grammar().Trees(input)
+//	...
+//	tree, err := cnf.Restore(trees[0])
+//
+// Transformations keep the language, not the ambiguity: when several trees
+// of the original grammar become one tree, that tree restores to one of
+// them. A symbol deriving the empty string in several ways always restores
+// to the first one found.
+package transform
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/liuzl/gearley"
+)
+
+// Transform is a transformation of grammars.
+type Transform func(*gearley.Grammar) (*Result, error)
+
+// Result is a grammar transformed from another one.
+type Result struct {
+	grammar  *gearley.Grammar
+	original *gearley.Grammar
+	// steps are the transformations from the original grammar, each
+	// mapping the rules of its grammar to the rules of the previous one
+	steps []step
+}
+
+// Grammar returns the transformed grammar.
+func (r *Result) Grammar() *gearley.Grammar {
+	return r.grammar
+}
+
+// Original returns the grammar the transformations started from.
+func (r *Result) Original() *gearley.Grammar {
+	return r.original
+}
+
+// Apply applies the transformations to g in order.
+func Apply(g *gearley.Grammar, transforms ...Transform) (*Result, error) {
+	result := &Result{grammar: g, original: g}
+	for _, t := range transforms {
+		next, err := t(result.grammar)
+		if err != nil {
+			return nil, err
+		}
+		result.grammar = next.grammar
+		result.steps = append(result.steps, next.steps...)
+	}
+	return result, nil
+}
+
+// Restore returns the tree of the original grammar that t, a tree of the
+// transformed grammar, stands for.
+func (r *Result) Restore(t *gearley.Tree) (*gearley.Tree, error) {
+	trees := []*gearley.Tree{t}
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		var err error
+		if trees, err = r.steps[i].restore(trees[0]); err != nil {
+			return nil, err
+		}
+		if len(trees) != 1 {
+			return nil, fmt.Errorf("transform: %v is not a tree of the grammar", t)
+		}
+	}
+	return trees[0], nil
+}
+
+// step maps every rule of a transformed grammar to the template of the trees
+// it stands for in the grammar before the transformation.
+type step map[*gearley.Rule][]part
+
+// part is a piece of a template.
+type part struct {
+	kind partKind
+	// hole is the index of a child in the right side of the rule
+	hole int
+	// rule is the rule of a node
+	rule  *gearley.Rule
+	parts []part
+}
+
+type partKind int
+
+const (
+	// the trees the child at hole stands for, spliced in place
+	holePart partKind = iota
+	// rule applied to the trees of parts
+	nodePart
+	// the one tree the child at hole stands for, with the trees of parts
+	// added before its children: a left factored prefix going back to
+	// the rule it was taken from
+	adoptPart
+)
+
+func hole(i int) part {
+	return part{kind: holePart, hole: i}
+}
+
+func node(r *gearley.Rule, parts ...part) part {
+	return part{kind: nodePart, rule: r, parts: parts}
+}
+
+// holes returns the holes from..to-1.
+func holes(from, to int) []part {
+	parts := []part{}
+	for i := from; i < to; i++ {
+		parts = append(parts, hole(i))
+	}
+	return parts
+}
+
+// identity is the template of a rule kept as it was.
+func identity(r *gearley.Rule) []part {
+	return []part{node(r, holes(0, len(r.Right()))...)}
+}
+
+// restore returns the trees t stands for before the step.
+func (s step) restore(t *gearley.Tree) ([]*gearley.Tree, error) {
+	if t.Rule == nil {
+		return []*gearley.Tree{t}, nil
+	}
+	template, ok := s[t.Rule]
+	if !ok {
+		return nil, fmt.Errorf("transform: rule %v is not in the grammar", t.Rule)
+	}
+	children := make([][]*gearley.Tree, len(t.Children))
+	for i, c := range t.Children {
+		var err error
+		if children[i], err = s.restore(c); err != nil {
+			return nil, err
+		}
+	}
+	trees, _ := instantiate(template, children, t.Start)
+	return trees, nil
+}
+
+// instantiate fills in the holes of parts with children, the parts starting
+// at pos in the input, and returns the trees along with where they end.
+func instantiate(parts []part, children [][]*gearley.Tree, pos int) ([]*gearley.Tree, int) {
+	trees := []*gearley.Tree{}
+	for _, p := range parts {
+		switch p.kind {
+		case holePart:
+			trees = append(trees, children[p.hole]...)
+			if n := len(children[p.hole]); n > 0 {
+				pos = children[p.hole][n-1].End
+			}
+		case nodePart:
+			sub, end := instantiate(p.parts, children, pos)
+			trees = append(trees, &gearley.Tree{Rule: p.rule, Start: pos, End: end, Children: sub})
+			pos = end
+		case adoptPart:
+			prefix, _ := instantiate(p.parts, children, pos)
+			adopted := children[p.hole][0]
+			trees = append(trees, &gearley.Tree{
+				Rule:     adopted.Rule,
+				Start:    pos,
+				End:      adopted.End,
+				Children: append(prefix, adopted.Children...),
+			})
+			pos = adopted.End
+		}
+	}
+	return trees, pos
+}
+
+// builder collects the rules of a transformed grammar.
+type builder struct {
+	// names holds the names of the non terminals in use
+	names map[string]bool
+	// keys holds the rules so far, see ruleKey
+	keys map[string]bool
+	// matchers numbers the terminals other than runes, told apart by value
+	matchers map[gearley.Symbol]int
+	rules    []*gearley.Rule
+	step     step
+}
+
+func newBuilder(g *gearley.Grammar) *builder {
+	b := &builder{names: map[string]bool{}, keys: map[string]bool{},
+		matchers: map[gearley.Symbol]int{}, step: step{}}
+	for _, r := range g.Rules() {
+		for _, s := range append([]gearley.Symbol{r.Left()}, r.Right()...) {
+			if n, ok := s.(gearley.NonTerminal); ok {
+				b.names[n.Name()] = true
+			}
+		}
+	}
+	return b
+}
+
+// fresh returns a new non terminal named after base.
+func (b *builder) fresh(base string) gearley.NonTerminal {
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s_%d", base, i)
+		if !b.names[name] {
+			b.names[name] = true
+			return gearley.NewNonTerminal(name)
+		}
+	}
+}
+
+// add adds r, standing for the trees of template, unless the grammar has
+// the same rule already.
+func (b *builder) add(r *gearley.Rule, template []part) {
+	key := b.ruleKey(r)
+	if b.keys[key] {
+		return
+	}
+	b.keys[key] = true
+	b.rules = append(b.rules, r)
+	b.step[r] = template
+}
+
+// ruleKey identifies the rules of the same symbols: unlike String, it tells
+// non terminals from terminals, and matchers of the same name apart.
+func (b *builder) ruleKey(r *gearley.Rule) string {
+	key := r.Left().Name() + " ->"
+	for _, s := range r.Right() {
+		switch s := s.(type) {
+		case gearley.NonTerminal:
+			key += " <" + s.Name() + ">"
+		case gearley.Terminal:
+			key += " " + s.String()
+		default:
+			// terminals of other packages may not be usable as keys
+			if !reflect.TypeOf(s).Comparable() {
+				key += " " + s.String()
+				continue
+			}
+			if _, ok := b.matchers[s]; !ok {
+				b.matchers[s] = len(b.matchers)
+			}
+			key += fmt.Sprintf(" %s#%d", s, b.matchers[s])
+		}
+	}
+	return key
+}
+
+// result returns the grammar built, the step to it, and the grammar g it
+// was built from.
+func (b *builder) result(g *gearley.Grammar) (*Result, error) {
+	if len(b.rules) == 0 {
+		return nil, fmt.Errorf("transform: %v derives no string", g.Start())
+	}
+	return &Result{grammar: gearley.NewGrammar(b.rules...), original: g, steps: []step{b.step}}, nil
+}
+
+// grammarIndex is what transformations look up in a grammar.
+type grammarIndex struct {
+	// symbols are the non terminals with rules, in order of first rule
+	symbols []gearley.NonTerminal
+	rules   map[gearley.NonTerminal][]*gearley.Rule
+}
+
+func index(g *gearley.Grammar) *grammarIndex {
+	ix := &grammarIndex{rules: map[gearley.NonTerminal][]*gearley.Rule{}}
+	for _, r := range g.Rules() {
+		if _, ok := ix.rules[r.Left()]; !ok {
+			ix.symbols = append(ix.symbols, r.Left())
+		}
+		ix.rules[r.Left()] = append(ix.rules[r.Left()], r)
+	}
+	return ix
+}
+
+// Reduce removes the non terminals deriving no string, then the ones the
+// start symbol does not reach, along with the rules using them. It fails
+// when the start symbol derives no string.
+func Reduce(g *gearley.Grammar) (*Result, error) {
+	productive := map[gearley.NonTerminal]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range g.Rules() {
+			if !productive[r.Left()] && allOf(r.Right(), productive) {
+				productive[r.Left()] = true
+				changed = true
+			}
+		}
+	}
+	if !productive[g.Start()] {
+		return nil, fmt.Errorf("transform: %v derives no string", g.Start())
+	}
+
+	ix := index(g)
+	reached := map[gearley.NonTerminal]bool{g.Start(): true}
+	queue := []gearley.NonTerminal{g.Start()}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, r := range ix.rules[n] {
+			if !allOf(r.Right(), productive) {
+				continue
+			}
+			for _, s := range r.Right() {
+				if m, ok := s.(gearley.NonTerminal); ok && !reached[m] {
+					reached[m] = true
+					queue = append(queue, m)
+				}
+			}
+		}
+	}
+
+	b := newBuilder(g)
+	for _, r := range g.Rules() {
+		if reached[r.Left()] && allOf(r.Right(), productive) {
+			b.add(r, identity(r))
+		}
+	}
+	return b.result(g)
+}
+
+// allOf reports whether the non terminals of symbols are all in set.
+func allOf(symbols []gearley.Symbol, set map[gearley.NonTerminal]bool) bool {
+	for _, s := range symbols {
+		if n, ok := s.(gearley.NonTerminal); ok && !set[n] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,292 @@
+package transform
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/liuzl/gearley"
+)
+
+var grammars = map[string]string{
+	"aabb": `<T> ::= "a" "b" | "a" <T> "b"`,
+	"palindromes": `<P> ::= "a" <P> "a" | "b" <P> "b"
+	                      | "a" | "b" | ""`,
+	"arithmetic": `<E> ::= <E> "+" <T> | <T>
+	               <T> ::= <T> "*" <F> | <F>
+	               <F> ::= "(" <E> ")" | "1" | "2" | "1" "2"`,
+	"nullable": `<S> ::= <S> <X> | ""
+	             <X> ::= <O> <O> <O> "x"
+	             <O> ::= "o" | ""`,
+	"units": `<A> ::= <B> | "a" <A>
+	          <B> ::= <C> | "b"
+	          <C> ::= <A> | "c" "c" "c" "c"`,
+	"dead": `<S> ::= "a" <S> | <D> | "b" <U>
+	         <D> ::= "d" <D>
+	         <U> ::= "u"
+	         <N> ::= "n"`,
+}
+
+var transforms = map[string]Transform{
+	"Reduce":           Reduce,
+	"EliminateEpsilon": EliminateEpsilon,
+	"EliminateUnit":    EliminateUnit,
+	"CNF":              CNF,
+	"LeftFactor":       LeftFactor,
+}
+
+// checkTree reports the ways tree is not a tree of g for input.
+func checkTree(t *testing.T, g *gearley.Grammar, tree *gearley.Tree, input []rune) {
+	t.Helper()
+	if tree.Rule == nil {
+		if tree.End != tree.Start+1 || input[tree.Start] != tree.Rune {
+			t.Errorf("leaf %v at %d-%d", tree, tree.Start, tree.End)
+		}
+		return
+	}
+	known := false
+	for _, r := range g.Rules() {
+		known = known || r == tree.Rule
+	}
+	right := tree.Rule.Right()
+	if !known || len(right) != len(tree.Children) {
+		t.Errorf("%v: not a rule of the grammar", tree)
+		return
+	}
+	pos := tree.Start
+	for i, c := range tree.Children {
+		if c.Start != pos {
+			t.Errorf("%v: child %d starts at %d, not %d", tree, i, c.Start, pos)
+		}
+		if c.Rule == nil && !right[i].Match(c.Rune) || c.Rule != nil && c.Rule.Left() != right[i] {
+			t.Errorf("%v: child %d is no %v", tree, i, right[i])
+		}
+		checkTree(t, g, c, input)
+		pos = c.End
+	}
+	if pos != tree.End {
+		t.Errorf("%v: ends at %d, not %d", tree, pos, tree.End)
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	for name, bnf := range grammars {
+		g, err := gearley.ParseBNF(bnf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs, err := gearley.Generate(g, 50, gearley.WithRand(rand.New(rand.NewSource(1))),
+			gearley.WithMaxDepth(6), gearley.WithCoverage(), gearley.WithMutations(0.3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, "")
+		for tname, transform := range transforms {
+			result, err := transform(g)
+			if err != nil {
+				t.Fatalf("%s %s: %v", tname, name, err)
+			}
+			tg := result.Grammar()
+			if tname == "CNF" && !IsCNF(tg) {
+				t.Errorf("%s is not in CNF:\n%s", name, tg.BNF())
+			}
+			for _, input := range inputs {
+				if (g.Parse(input) == nil) != (tg.Parse(input) == nil) {
+					t.Errorf("%s %s: %q: %v, originally %v", tname, name, input, tg.Parse(input), g.Parse(input))
+					continue
+				}
+				trees, err := tg.Trees(input, gearley.WithMaxTrees(20))
+				if err != nil {
+					continue
+				}
+				originals, _ := g.Trees(input)
+				want := map[string]bool{}
+				for _, tree := range originals {
+					want[tree.String()] = true
+				}
+				for _, tree := range trees {
+					restored, err := result.Restore(tree)
+					if err != nil {
+						t.Errorf("%s %s: %v: %v", tname, name, tree, err)
+						continue
+					}
+					checkTree(t, g, restored, []rune(input))
+					if !want[restored.String()] {
+						t.Errorf("%s %s: %v restores to %v", tname, name, tree, restored)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	g, _ := gearley.ParseBNF(grammars["dead"])
+	result, err := Reduce(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<S> ::= "a" <S>
+      | "b" <U>
+<U> ::= "u"
+`
+	if got := result.Grammar().BNF(); got != want {
+		t.Errorf("Reduce:\n%s\nwant\n%s", got, want)
+	}
+	if len(result.Grammar().Validate()) != 0 {
+		t.Errorf("Validate: %v", result.Grammar().Validate())
+	}
+
+	g, _ = gearley.ParseBNF(`<S> ::= "a" <S>`)
+	if _, err := CNF(g); err == nil || err.Error() != "transform: S derives no string" {
+		t.Errorf("CNF of an empty language: %v", err)
+	}
+}
+
+func TestEliminateEpsilon(t *testing.T) {
+	g, _ := gearley.ParseBNF(`<S> ::= <A> "b" <A>
+	                          <A> ::= "a" | ""`)
+	result, err := EliminateEpsilon(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<S> ::= <A> "b" <A>
+      | "b" <A>
+      | <A> "b"
+      | "b"
+<A> ::= "a"
+`
+	if got := result.Grammar().BNF(); got != want {
+		t.Errorf("EliminateEpsilon:\n%s\nwant\n%s", got, want)
+	}
+	trees, err := result.Grammar().Trees("ba")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := result.Restore(trees[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.String(); got != "(S (A) 'b' (A 'a'))" {
+		t.Errorf("Restore = %s", got)
+	}
+	if a := tree.Children[0]; a.Start != 0 || a.End != 0 {
+		t.Errorf("A spans %d-%d", a.Start, a.End)
+	}
+
+	g, _ = gearley.ParseBNF(`<S> ::= "a" <S> | ""`)
+	result, _ = EliminateEpsilon(g)
+	want = `<S_1> ::= <S>
+        | ""
+<S> ::= "a" <S>
+      | "a"
+`
+	if got := result.Grammar().BNF(); got != want {
+		t.Errorf("EliminateEpsilon, nullable start:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEliminateUnit(t *testing.T) {
+	g, _ := gearley.ParseBNF(grammars["units"])
+	result, err := EliminateUnit(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range result.Grammar().Rules() {
+		if isUnit(r) {
+			t.Errorf("unit rule %v", r)
+		}
+	}
+	trees, _ := result.Grammar().Trees("ab")
+	tree, err := result.Restore(trees[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.String(); got != "(A 'a' (A (B 'b')))" {
+		t.Errorf("Restore = %s", got)
+	}
+}
+
+func TestCNF(t *testing.T) {
+	g, _ := gearley.ParseBNF(`<S> ::= "a" <S> "b" "c" | ""`)
+	result, err := CNF(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<S_3> ::= ""
+        | <'a'> <S_1>
+<S> ::= <'a'> <S_1>
+<S_1> ::= <S> <S_2>
+        | <'b'> <'c'>
+<S_2> ::= <'b'> <'c'>
+<'a'> ::= "a"
+<'b'> ::= "b"
+<'c'> ::= "c"
+`
+	if got := result.Grammar().BNF(); got != want {
+		t.Errorf("CNF:\n%s\nwant\n%s", got, want)
+	}
+	trees, _ := result.Grammar().Trees("aabcbc")
+	tree, err := result.Restore(trees[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.String(); got != "(S 'a' (S 'a' (S) 'b' 'c') 'b' 'c')" {
+		t.Errorf("Restore = %s", got)
+	}
+	if !IsCNF(result.Grammar()) || IsCNF(g) {
+		t.Errorf("IsCNF")
+	}
+}
+
+func TestCNFMatchers(t *testing.T) {
+	// two matchers of the same name, for digits and letters
+	digit := gearley.TerminalFunc("x", unicode.IsDigit)
+	letter := gearley.TerminalFunc("x", unicode.IsLetter)
+	S := gearley.NewNonTerminal("S")
+	g := gearley.NewGrammar(
+		gearley.NewRule(S, digit, letter),
+		gearley.NewRule(S, digit),
+		gearley.NewRule(S, letter),
+	)
+	result, err := CNF(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for input, want := range map[string]bool{"1a": true, "1": true, "a": true, "a1": false, "11": false, "aa": false} {
+		if got := result.Grammar().Parse(input) == nil; got != want {
+			t.Errorf("CNF parses %q: %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestLeftFactor(t *testing.T) {
+	g, _ := gearley.ParseBNF(`<S> ::= "i" "f" <S> "e" <S> | "i" "f" <S> | "i" "d" | "x"`)
+	result, err := LeftFactor(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<S> ::= "i" <S_1>
+      | "x"
+<S_1> ::= "f" <S> <S_1_1>
+        | "d"
+<S_1_1> ::= "e" <S>
+          | ""
+`
+	if got := result.Grammar().BNF(); got != want {
+		t.Errorf("LeftFactor:\n%s\nwant\n%s", got, want)
+	}
+	trees, _ := result.Grammar().Trees("ifxex")
+	got := []string{}
+	for _, tree := range trees {
+		restored, err := result.Restore(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, restored.String())
+	}
+	if want := "(S 'i' 'f' (S 'x') 'e' (S 'x'))"; strings.Join(got, " ") != want {
+		t.Errorf("Restore = %q, want %s", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package gearley
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tree is a derivation of the input from Start to End: a rule applied to the
+// trees of its right side or, when Rule is nil, a leaf holding the rune of
+// the input a terminal matched.
+type Tree struct {
+	Rule       *Rule
+	Rune       rune
+	Start, End int
+	Children   []*Tree
+}
+
+// String returns the tree as an S-expression, leaves written like the
+// terminals matching them: (T 'a' (T 'a' 'b') 'b').
+func (t *Tree) String() string {
+	var b strings.Builder
+	t.write(&b)
+	return b.String()
+}
+
+func (t *Tree) write(b *strings.Builder) {
+	if t.Rule == nil {
+		b.WriteString(NewTerminal(t.Rune).String())
+		return
+	}
+	b.WriteString("(" + t.Rule.left.name)
+	for _, c := range t.Children {
+		b.WriteString(" ")
+		c.write(b)
+	}
+	b.WriteString(")")
+}
+
+// Print writes the tree indented, a node per line: the rule and span of a
+// node, the rune of a leaf.
+func (t *Tree) Print(w io.Writer) {
+	t.print(w, 0)
+}
+
+func (t *Tree) print(w io.Writer, level int) {
+	indentation := strings.Repeat("  ", level)
+	if t.Rule == nil {
+		fmt.Fprintf(w, "%s%q\n", indentation, t.Rune)
+		return
+	}
+	fmt.Fprintf(w, "%s%s [%d-%d]\n", indentation, t.Rule, t.Start, t.End)
+	for _, c := range t.Children {
+		c.print(w, level+1)
+	}
+}
+
+// PTB returns the tree in the bracketed format of the Penn Treebank, indented,
+// with parentheses escaped as -LRB- and -RRB- and spaces as _.
+func (t *Tree) PTB() string {
+	var b strings.Builder
+	t.writePTB(&b, 0)
+	b.WriteString("\n")
+	return b.String()
+}
+
+var ptbEscapes = strings.NewReplacer("(", "-LRB-", ")", "-RRB-", " ", "_")
+
+func (t *Tree) writePTB(b *strings.Builder, indent int) {
+	if t.Rule == nil {
+		b.WriteString(ptbEscapes.Replace(string(t.Rune)))
+		return
+	}
+	b.WriteString("(" + ptbEscapes.Replace(t.Rule.left.name))
+	leaves := true
+	for _, c := range t.Children {
+		leaves = leaves && c.Rule == nil
+	}
+	for _, c := range t.Children {
+		if leaves {
+			b.WriteString(" ")
+		} else {
+			// one line per child, below the label
+			b.WriteString("\n" + strings.Repeat("  ", indent+1))
+		}
+		c.writePTB(b, indent+1)
+	}
+	b.WriteString(")")
+}
+
+// jsonTree is the JSON form of a tree: the symbol of a node or the rune of a
+// leaf, and the span of both.
+type jsonTree struct {
+	Symbol   string  `json:"symbol,omitempty"`
+	Token    string  `json:"token,omitempty"`
+	Span     [2]int  `json:"span"`
+	Children []*Tree `json:"children,omitempty"`
+}
+
+// MarshalJSON encodes the tree the way package earley3 encodes its trees,
+// with a rune for token:
+//
+//	{"symbol": "N", "span": [2, 3], "children": [{"token": "7", "span": [2, 3]}]}
+func (t *Tree) MarshalJSON() ([]byte, error) {
+	jt := jsonTree{Span: [2]int{t.Start, t.End}, Children: t.Children}
+	if t.Rule == nil {
+		jt.Token = string(t.Rune)
+	} else {
+		jt.Symbol = t.Rule.left.name
+	}
+	return json.Marshal(jt)
+}
+
+// WithMaxTrees stops Trees with a *LimitError once it has n trees, if the
+// input has more.
+func WithMaxTrees(n int) Option {
+	return func(cfg *config) {
+		cfg.maxTrees = n
+	}
+}
+
+// Trees returns the trees of input, or a *ParseError when input is not a
+// sentence of the grammar. Derivations going round a cycle of the grammar,
+// a symbol deriving itself over the same span, are cut the way Score cuts
+// them: Trees returns as many trees as Score with semiring.Counting counts.
+//
+// An ambiguous input may have a number of trees exponential in its length:
+// count them first with Score and semiring.Counting, or set WithMaxTrees.
+// Trees share their common subtrees.
+func (g *Grammar) Trees(input string, opts ...Option) ([]*Tree, error) {
+	runes := stringToRunes(input)
+	cfg := newConfig(opts)
+	st, err := g.buildState(runeInput(runes), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !g.accepts(st) {
+		return nil, newParseError(g, st, runeInput(runes))
+	}
+	tb := &treeBuilder{
+		g:      g,
+		st:     st,
+		runes:  runes,
+		max:    cfg.maxTrees,
+		memo:   map[scoreKey][][]*Tree{},
+		active: map[scoreKey]bool{},
+	}
+	trees := []*Tree{}
+	last := len(runes)
+	for _, item := range st.getAt(last).items {
+		if g.isCompletedStart(item) {
+			trees = tb.limit(append(trees, tb.complete(item, last)...))
+		}
+	}
+	if tb.truncated {
+		items := 0
+		for i := range *st {
+			items += st.getAt(i).length()
+		}
+		return trees, &LimitError{Pos: last, Items: items, Limit: LimitTrees, Max: tb.max}
+	}
+	return trees, nil
+}
+
+// treeBuilder walks the derivations recorded in a chart like scorer does,
+// building trees instead of values.
+type treeBuilder struct {
+	g     *Grammar
+	st    *state
+	runes []rune
+	// max is the most trees kept for any item, 0 for no limit
+	max int
+	// truncated tells whether trees were dropped to keep to max
+	truncated bool
+	memo      map[scoreKey][][]*Tree
+	active    map[scoreKey]bool
+}
+
+// limit drops the trees beyond the maximum.
+func (tb *treeBuilder) limit(trees []*Tree) []*Tree {
+	if tb.max > 0 && len(trees) > tb.max {
+		tb.truncated = true
+		return trees[:tb.max]
+	}
+	return trees
+}
+
+// complete returns the trees of the completed item in S(pos).
+func (tb *treeBuilder) complete(item earleyItem, pos int) []*Tree {
+	trees := []*Tree{}
+	for _, children := range tb.inside(item, pos) {
+		trees = append(trees, &Tree{
+			Rule:     tb.g.ruleOf(item),
+			Start:    int(item.index),
+			End:      pos,
+			Children: children,
+		})
+	}
+	return trees
+}
+
+// inside returns the sequences of trees of the symbols of item before the
+// dot, spanning the input from item.index to pos.
+func (tb *treeBuilder) inside(item earleyItem, pos int) [][]*Tree {
+	key := scoreKey{item: item, pos: pos}
+	if seqs, ok := tb.memo[key]; ok {
+		return seqs
+	}
+	if tb.active[key] {
+		return nil
+	}
+	tb.active[key] = true
+	defer delete(tb.active, key)
+
+	seqs := [][]*Tree{}
+	if item.dot == 0 {
+		if int(item.index) == pos {
+			seqs = append(seqs, nil)
+		}
+		tb.memo[key] = seqs
+		return seqs
+	}
+	// extend appends last to every sequence of prev
+	extend := func(prev [][]*Tree, last []*Tree) {
+		for _, seq := range prev {
+			for _, t := range last {
+				if tb.max > 0 && len(seqs) == tb.max {
+					tb.truncated = true
+					return
+				}
+				seqs = append(seqs, append(append([]*Tree(nil), seq...), t))
+			}
+		}
+	}
+	prev := earleyItem{rule: item.rule, dot: item.dot - 1, index: item.index}
+	switch s := tb.g.getSymbolAt(item, int(item.dot-1)).(type) {
+	case NonTerminal:
+		for _, c := range tb.st.getAt(pos).items {
+			if c.index < item.index || !tb.g.isCompleted(c) || tb.g.ruleOf(c).left != s {
+				continue
+			}
+			if !tb.st.getAt(int(c.index)).hasItem(prev) {
+				continue
+			}
+			extend(tb.inside(prev, int(c.index)), tb.complete(c, pos))
+		}
+	default:
+		if pos > int(item.index) && s.Match(tb.runes[pos-1]) &&
+			tb.st.getAt(pos-1).hasItem(prev) {
+			leaf := &Tree{Rune: tb.runes[pos-1], Start: pos - 1, End: pos}
+			extend(tb.inside(prev, pos-1), []*Tree{leaf})
+		}
+	}
+	tb.memo[key] = seqs
+	return seqs
+}
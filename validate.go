@@ -0,0 +1,65 @@
+package gearley
+
+import "fmt"
+
+// Validate returns the problems of the grammar, in order: non terminals
+// without rules, non terminals the start symbol does not reach, non terminals
+// deriving no string, and rules given twice. A grammar with problems still
+// parses, but it has dead parts.
+func (g *Grammar) Validate() []error {
+	problems := []error{}
+	if len(g.rules) == 0 {
+		return append(problems, fmt.Errorf("gearley: no rules"))
+	}
+	order := []NonTerminal{}
+	seen := map[NonTerminal]bool{}
+	for _, r := range g.rules {
+		for _, s := range append([]Symbol{r.left}, r.right...) {
+			if n, ok := s.(NonTerminal); ok && !seen[n] {
+				seen[n] = true
+				order = append(order, n)
+			}
+		}
+	}
+	for _, n := range order {
+		if len(g.rulesBySymbol[n]) == 0 {
+			problems = append(problems, fmt.Errorf("gearley: %v has no rules", n))
+		}
+	}
+
+	reached := map[NonTerminal]bool{g.start(): true}
+	queue := []NonTerminal{g.start()}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, r := range g.rulesBySymbol[n] {
+			for _, s := range r.right {
+				if m, ok := s.(NonTerminal); ok && !reached[m] {
+					reached[m] = true
+					queue = append(queue, m)
+				}
+			}
+		}
+	}
+	for _, n := range order {
+		if !reached[n] {
+			problems = append(problems, fmt.Errorf("gearley: %v is not reachable from %v", n, g.start()))
+		}
+	}
+
+	minDepth := g.minDepths()
+	for _, n := range order {
+		if _, ok := minDepth[n]; !ok && len(g.rulesBySymbol[n]) > 0 {
+			problems = append(problems, fmt.Errorf("gearley: %v derives no string", n))
+		}
+	}
+
+	rules := map[string]bool{}
+	for _, r := range g.rules {
+		if rules[r.String()] {
+			problems = append(problems, fmt.Errorf("gearley: rule %v is given twice", r))
+		}
+		rules[r.String()] = true
+	}
+	return problems
+}
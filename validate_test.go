@@ -0,0 +1,39 @@
+package gearley
+
+import "testing"
+
+func Test_Validate(t *testing.T) {
+	S := NewNonTerminal("S")
+	A := NewNonTerminal("A")
+	L := NewNonTerminal("L")
+	U := NewNonTerminal("U")
+	X := NewNonTerminal("X")
+	g := NewGrammar(
+		NewRule(S, A, NewTerminal('s')), // S -> A 's'
+		NewRule(S, L),                   // S -> L
+		NewRule(S, A, NewTerminal('s')), // S -> A 's'
+		NewRule(A),                      // A ->
+		NewRule(L, L, NewTerminal('l')), // L -> L 'l'
+		NewRule(U, U, X),                // U -> U X
+	)
+	want := []string{
+		"gearley: X has no rules",
+		"gearley: U is not reachable from S",
+		"gearley: X is not reachable from S",
+		"gearley: L derives no string",
+		"gearley: U derives no string",
+		"gearley: rule S -> A 's' is given twice",
+	}
+	problems := g.Validate()
+	if len(problems) != len(want) {
+		t.Fatalf("Validate() = %v, want %v", problems, want)
+	}
+	for i, err := range problems {
+		if err.Error() != want[i] {
+			t.Errorf("problem %d = %v, want %v", i, err, want[i])
+		}
+	}
+	if problems := NewGrammar(NewRule(S, A), NewRule(A)).Validate(); len(problems) != 0 {
+		t.Errorf("Validate() = %v, want none", problems)
+	}
+}
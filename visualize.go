@@ -0,0 +1,166 @@
+package gearley
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+)
+
+// WriteChartHTML parses input and writes the chart as an HTML table to w:
+// one column per state set, headed by its position and the rune it scans,
+// with the items of the set below and their dot positions marked with FLAT_DOT.
+// Completed items have the class "completed".
+// A parse stopped by one of the limits of opts writes the chart filled so
+// far, and returns the *LimitError.
+func (g *Grammar) WriteChartHTML(w io.Writer, input string, opts ...Option) error {
+	runes := stringToRunes(input)
+	st, err := g.buildState(runeInput(runes), newConfig(opts))
+	if werr := g.writeChartHTML(w, st, runes); werr != nil {
+		return werr
+	}
+	return err
+}
+
+func (g *Grammar) writeChartHTML(w io.Writer, st *state, runes []rune) error {
+	ew := &errWriter{w: w}
+	ew.printf("<table class=\"earley-chart\">\n<tr>")
+	rows := 0
+	for i, set := range *st {
+		next := ""
+		if i < len(runes) {
+			next = fmt.Sprintf(" %q", runes[i])
+		}
+		ew.printf("<th>S(%d)%s</th>", i, html.EscapeString(next))
+		if set.length() > rows {
+			rows = set.length()
+		}
+	}
+	ew.printf("</tr>\n")
+	for row := 0; row < rows; row++ {
+		ew.printf("<tr>")
+		for _, set := range *st {
+			if row >= set.length() {
+				ew.printf("<td></td>")
+				continue
+			}
+			item := set.items[row]
+			class := ""
+			if g.isCompleted(item) {
+				class = ` class="completed"`
+			}
+			ew.printf("<td%s>%s</td>", class, html.EscapeString(g.view(item).String()))
+		}
+		ew.printf("</tr>\n")
+	}
+	ew.printf("</table>\n")
+	return ew.err
+}
+
+// WriteChart parses input and writes the chart as text to w, for reading in
+// a terminal: each state set headed by its position and the rune it scans,
+// its items below one per line.
+// A parse stopped by one of the limits of opts writes the chart filled so
+// far, and returns the *LimitError.
+func (g *Grammar) WriteChart(w io.Writer, input string, opts ...Option) error {
+	runes := stringToRunes(input)
+	st, err := g.buildState(runeInput(runes), newConfig(opts))
+	ew := &errWriter{w: w}
+	for i, set := range *st {
+		next := ""
+		if i < len(runes) {
+			next = fmt.Sprintf(" %q", runes[i])
+		}
+		ew.printf("S(%d)%s\n=======================================\n", i, next)
+		for _, item := range set.items {
+			ew.printf("%s\n", g.view(item))
+		}
+		ew.printf("\n")
+	}
+	if ew.err != nil {
+		return ew.err
+	}
+	return err
+}
+
+// WriteDOT writes the tree in the Graphviz DOT language, its runes boxed.
+func (t *Tree) WriteDOT(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("digraph tree {\n")
+	id := 0
+	var walk func(t *Tree) int
+	walk = func(t *Tree) int {
+		me := id
+		id++
+		if t.Rule == nil {
+			ew.printf("  n%d [shape=box, label=%s];\n", me, strconv.Quote(string(t.Rune)))
+			return me
+		}
+		ew.printf("  n%d [label=%s];\n", me, strconv.Quote(fmt.Sprintf("%s [%d-%d]", t.Rule.left, t.Start, t.End)))
+		for _, c := range t.Children {
+			ew.printf("  n%d -> n%d;\n", me, walk(c))
+		}
+		return me
+	}
+	walk(t)
+	ew.printf("}\n")
+	return ew.err
+}
+
+// WriteDOT writes the forest in the Graphviz DOT language. A node with
+// several families is ambiguous: it is drawn in red, with one diamond per
+// family.
+func (f *Forest) WriteDOT(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("digraph forest {\n")
+	nodeID := func(n *ForestNode) string {
+		if n.Symbol == nil {
+			return fmt.Sprintf("t%d", n.Start)
+		}
+		return strconv.Quote(fmt.Sprintf("%s_%d_%d", n.Symbol, n.Start, n.End))
+	}
+	leaves := map[int]rune{}
+	f.walk(func(n *ForestNode) {
+		text := strconv.Quote(n.String())
+		if len(n.Families) > 1 {
+			ew.printf("  %s [label=%s, color=red, fontcolor=red, penwidth=2];\n", nodeID(n), text)
+		} else {
+			ew.printf("  %s [label=%s];\n", nodeID(n), text)
+		}
+		for i, fam := range n.Families {
+			from := nodeID(n)
+			if len(n.Families) > 1 {
+				// only ambiguous nodes get their families drawn
+				from = strconv.Quote(fmt.Sprintf("%s_%d_%d/%d", n.Symbol, n.Start, n.End, i))
+				ew.printf("  %s [shape=diamond, color=red, label=%s];\n",
+					from, strconv.Quote(fam.Rule.String()))
+				ew.printf("  %s -> %s [color=red];\n", nodeID(n), from)
+			}
+			for _, c := range fam.Children {
+				if c.Symbol == nil {
+					leaves[c.Start] = c.Rune
+				}
+				ew.printf("  %s -> %s;\n", from, nodeID(c))
+			}
+		}
+	})
+	for pos := f.Root.Start; pos < f.Root.End; pos++ {
+		if r, ok := leaves[pos]; ok {
+			ew.printf("  t%d [shape=box, label=%s];\n", pos, strconv.Quote(string(r)))
+		}
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+// errWriter keeps the first error of a series of writes.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}